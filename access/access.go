@@ -0,0 +1,32 @@
+// Package access holds the types shared by the webapp middlewares that control
+// access to a service and the stores that back them, such as those in the
+// sqldb package: rate limit results, permissions and API keys. It only depends
+// on the standard library so that the stores do not need to import the server
+// code.
+package access
+
+import (
+	"errors"
+	"slices"
+)
+
+var (
+	// ErrInvalidToken is returned, possibly wrapped, when an authentication
+	// token is unknown, expired, revoked or otherwise not acceptable.
+	ErrInvalidToken = errors.New("invalid authentication token")
+	// ErrAPIKeyNotFound is returned when no API key matches a hash or ID.
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrUnavailable is returned, possibly wrapped, when a service needed to
+	// authenticate or authorise a request, such as an auth service, is
+	// temporarily unavailable.
+	ErrUnavailable = errors.New("service unavailable")
+)
+
+// Permissions is a set of permission codes, such as movies:read and
+// movies:write.
+type Permissions []string
+
+// Include reports whether code is one of the Permissions.
+func (p Permissions) Include(code string) bool {
+	return slices.Contains(p, code)
+}
@@ -0,0 +1,97 @@
+package access
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"slices"
+	"time"
+)
+
+// APIKey is an API key issued to a principal for machine-to-machine access. Only
+// the SHA-256 Hash of the key is stored, along with its Prefix so that people
+// can tell their keys apart. It is the principal for requests authenticated
+// with it, and its permissions are limited to its Scopes.
+type APIKey struct {
+	KeyID       int64
+	PrincipalID string
+	Name        string
+	Prefix      string
+	Hash        []byte
+	Scopes      []string
+	// ExpiresAt is when the key stops being accepted. The zero time means that
+	// it never expires.
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	CreatedAt  time.Time
+	// PrincipalActivated records whether the account of the principal the key
+	// was issued to was activated when the key was looked up. It is set by the
+	// webapp.APIKeyStore rather than stored with the key, so that deactivating
+	// an account also stops its keys from being treated as activated.
+	PrincipalActivated bool
+}
+
+// NewAPIKey generates a new API key for the principal with the given ID. The
+// key is made up of prefix, which identifies the kind of key, such as
+// myapp_live, and a random secret. The key itself is returned along with an
+// APIKey holding its hash, which should be stored with a webapp.APIKeyStore.
+// The key must be given to the client straight away as it cannot be recovered.
+func NewAPIKey(prefix, principalID, name string, scopes []string, expiresAt time.Time) (string, *APIKey, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := &APIKey{
+		PrincipalID: principalID,
+		Name:        name,
+		// Keep a few characters of the secret so that keys with the same prefix
+		// can be told apart.
+		Prefix:    key[:len(prefix)+5],
+		Hash:      HashAPIKey(key),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	return key, apiKey, nil
+}
+
+// HashAPIKey returns the SHA-256 hash of key. As keys are long and random, a
+// fast hash is sufficient and allows them to be looked up by their hash.
+func HashAPIKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// Expired reports whether the key has passed its expiry time.
+func (k *APIKey) Expired() bool {
+	return !k.ExpiresAt.IsZero() && time.Now().After(k.ExpiresAt)
+}
+
+// ID implements the webapp.Principal interface. It returns the ID of the
+// principal the key was issued to, not the KeyID of the key itself.
+func (k *APIKey) ID() string {
+	return k.PrincipalID
+}
+
+// IsActivated implements the webapp.Principal interface. It reports whether the
+// account of the principal the key was issued to is activated.
+func (k *APIKey) IsActivated() bool {
+	return k.PrincipalActivated
+}
+
+// IsScoped implements the webapp.ScopedPrincipal interface, so that an API key
+// is never granted more than its Scopes, even if the principal it was issued
+// to has other permissions.
+func (k *APIKey) IsScoped() bool {
+	return true
+}
+
+// HasPermission implements the webapp.Principal interface.
+func (k *APIKey) HasPermission(code string) bool {
+	return slices.Contains(k.Scopes, code)
+}
@@ -0,0 +1,64 @@
+package access
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, apiKey, err := NewAPIKey("myapp_live", "user-1", "CI", []string{"movies:read"}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(key, "myapp_live_") {
+		t.Errorf("got key %q; want it to start with the prefix", key)
+	}
+	if !strings.HasPrefix(key, apiKey.Prefix) || len(apiKey.Prefix) != len("myapp_live_")+4 {
+		t.Errorf("got stored prefix %q for key %q", apiKey.Prefix, key)
+	}
+	if !bytes.Equal(apiKey.Hash, HashAPIKey(key)) {
+		t.Error("got a stored hash that does not match the key")
+	}
+	if bytes.Contains(apiKey.Hash, []byte(key)) {
+		t.Error("got the key itself in the stored hash")
+	}
+
+	other, _, err := NewAPIKey("myapp_live", "user-1", "CI", nil, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == key {
+		t.Error("got the same key twice")
+	}
+}
+
+func TestAPIKeyPrincipal(t *testing.T) {
+	tests := []struct {
+		name        string
+		expiresAt   time.Time
+		wantExpired bool
+	}{
+		{"no expiry", time.Time{}, false},
+		{"expires in the future", time.Now().Add(time.Hour), false},
+		{"expired", time.Now().Add(-time.Second), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &APIKey{PrincipalID: "user-1", Scopes: []string{"movies:read"}, ExpiresAt: tt.expiresAt}
+
+			if got := k.Expired(); got != tt.wantExpired {
+				t.Errorf("got expired %t; want %t", got, tt.wantExpired)
+			}
+			if k.ID() != "user-1" {
+				t.Errorf("got ID %q; want the principal ID", k.ID())
+			}
+			if !k.IsScoped() || !k.HasPermission("movies:read") || k.HasPermission("movies:write") {
+				t.Error("got permissions that do not match the scopes")
+			}
+		})
+	}
+}
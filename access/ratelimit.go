@@ -0,0 +1,56 @@
+package access
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimitResult is the outcome of checking a request against a rate limit.
+// It holds the details needed for the RateLimit-* and Retry-After response
+// headers.
+type RateLimitResult struct {
+	// Allowed is true if the request is permitted.
+	Allowed bool
+	// Limit is the maximum number of tokens the client can have.
+	Limit int
+	// Remaining is the number of whole tokens the client has left.
+	Remaining int
+	// Reset is how long it will take for the client's tokens to be
+	// completely refilled.
+	Reset time.Duration
+	// RetryAfter is how long the client must wait before there will be enough
+	// tokens for the request. It is zero if the request was allowed.
+	RetryAfter time.Duration
+}
+
+// NewRateLimitResult returns a RateLimitResult for a token bucket that has the
+// given number of tokens left after the request, and that refills at rps
+// tokens per second up to a maximum of burst tokens. It is intended to help
+// with implementing a webapp.RateLimitStore.
+func NewRateLimitResult(allowed bool, tokens, rps float64, burst, cost int) RateLimitResult {
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     burst,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+	}
+
+	if rps <= 0 {
+		return result
+	}
+
+	toDuration := func(t float64) time.Duration {
+		return time.Duration(math.Max(0, t) / rps * float64(time.Second))
+	}
+
+	result.Reset = toDuration(float64(burst) - tokens)
+	if !allowed {
+		result.RetryAfter = toDuration(float64(cost) - tokens)
+		// A request costing more than the burst can never be allowed, so the
+		// best the client can do is wait for a full bucket.
+		if cost > burst {
+			result.RetryAfter = result.Reset
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,73 @@
+package access
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewRateLimitResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed bool
+		tokens  float64
+		rps     float64
+		burst   int
+		cost    int
+		want    RateLimitResult
+	}{
+		{
+			name:    "allowed with tokens left",
+			allowed: true,
+			tokens:  7.5,
+			rps:     1,
+			burst:   10,
+			cost:    1,
+			want:    RateLimitResult{Allowed: true, Limit: 10, Remaining: 7, Reset: 2500 * time.Millisecond},
+		},
+		{
+			name:    "denied",
+			allowed: false,
+			tokens:  0.5,
+			rps:     2,
+			burst:   10,
+			cost:    2,
+			want:    RateLimitResult{Limit: 10, Remaining: 0, Reset: 4750 * time.Millisecond, RetryAfter: 750 * time.Millisecond},
+		},
+		{
+			name:    "denied with cost over burst",
+			allowed: false,
+			tokens:  4,
+			rps:     1,
+			burst:   5,
+			cost:    8,
+			want:    RateLimitResult{Limit: 5, Remaining: 4, Reset: time.Second, RetryAfter: time.Second},
+		},
+		{
+			name:    "negative tokens",
+			allowed: false,
+			tokens:  -1,
+			rps:     1,
+			burst:   5,
+			cost:    1,
+			want:    RateLimitResult{Limit: 5, Remaining: 0, Reset: 6 * time.Second, RetryAfter: 2 * time.Second},
+		},
+		{
+			name:    "no refill",
+			allowed: false,
+			tokens:  0,
+			rps:     0,
+			burst:   5,
+			cost:    1,
+			want:    RateLimitResult{Limit: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewRateLimitResult(tt.allowed, tt.tokens, tt.rps, tt.burst, tt.cost)
+			if got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
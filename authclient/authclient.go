@@ -0,0 +1,243 @@
+// Package authclient is a client for a remote authentication service, such as
+// one built with this toolkit. It introspects bearer tokens and fetches users'
+// permissions over JSend, caching the responses and stopping requests with a
+// circuit breaker when the service is failing, and can be plugged into the
+// webapp.Authenticate and webapp.RequirePermission middlewares.
+//
+// The auth service is expected to provide the following endpoints:
+//
+//	POST /v1/tokens/introspect      {"token": "..."} -> {"user": User}
+//	GET  /v1/users/:id/permissions  -> {"permissions": ["movies:read", ...]}
+//
+// An unknown or expired token should get a 401 or 404 fail response. A user
+// with no permissions may get either an empty list or a 404 fail response.
+package authclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+	"github.com/m5lapp/go-service-toolkit/config"
+	"github.com/m5lapp/go-service-toolkit/internal/cache"
+	"github.com/m5lapp/go-service-toolkit/serialisation/jsonz"
+	"github.com/m5lapp/go-service-toolkit/webapp"
+)
+
+// Errors returned by call() for 401 and 404 responses, which each endpoint
+// maps to its own result.
+var (
+	errUnauthorized = errors.New("auth service returned status 401")
+	errNotFound     = errors.New("auth service returned status 404")
+)
+
+// maxCachedInvalidTokens is the number of tokens that the auth service did not
+// accept which a Client remembers. They are kept apart from the valid tokens so
+// that clients sending made up tokens cannot push the valid ones out.
+const maxCachedInvalidTokens = 1_000
+
+// User is the Principal for a request authenticated by the auth service.
+type User struct {
+	UserID      string             `json:"id"`
+	Activated   bool               `json:"activated"`
+	Permissions access.Permissions `json:"permissions"`
+}
+
+// ID implements the webapp.Principal interface.
+func (u *User) ID() string {
+	return u.UserID
+}
+
+// IsActivated implements the webapp.Principal interface.
+func (u *User) IsActivated() bool {
+	return u.Activated
+}
+
+// HasPermission implements the webapp.Principal interface.
+func (u *User) HasPermission(code string) bool {
+	return u.Permissions.Include(code)
+}
+
+// Client calls the auth service in its config.AuthService. It implements the
+// webapp.TokenStore and webapp.PermissionStore interfaces.
+type Client struct {
+	cfg     config.AuthService
+	baseURL string
+
+	tokens        *cache.Cache[*User]
+	invalidTokens *cache.Cache[struct{}]
+	permissions   *cache.Cache[access.Permissions]
+	breaker       *breaker
+}
+
+// New returns a pointer to a new Client for the auth service in cfg. Any of
+// the Timeout, BreakerThreshold and BreakerCooldown that are not set are given
+// their default values from the config package.
+func New(cfg config.AuthService) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = config.DefaultAuthServiceTimeout
+	}
+	if cfg.BreakerThreshold <= 0 {
+		cfg.BreakerThreshold = config.DefaultAuthServiceBreakerThreshold
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = config.DefaultAuthServiceBreakerCooldown
+	}
+
+	return &Client{
+		cfg:           cfg,
+		baseURL:       cfg.BaseURL(),
+		tokens:        cache.New[*User](cfg.CacheTTL, cache.DefaultCapacity),
+		invalidTokens: cache.New[struct{}](cfg.CacheTTL, maxCachedInvalidTokens),
+		permissions:   cache.New[access.Permissions](cfg.CacheTTL, cache.DefaultCapacity),
+		breaker:       &breaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown},
+	}
+}
+
+// Introspect returns the User that token was issued to. If the auth service
+// does not accept the token, then the error wraps access.ErrInvalidToken.
+// Both valid and invalid tokens are cached, by a hash of the token, with the
+// invalid ones kept in a smaller cache of their own.
+func (c *Client) Introspect(ctx context.Context, token string) (*User, error) {
+	key := tokenCacheKey(token)
+
+	user, found := c.tokens.Get(key)
+	if found {
+		return user, nil
+	}
+	if _, found := c.invalidTokens.Get(key); found {
+		return nil, access.ErrInvalidToken
+	}
+
+	var data struct {
+		User *User `json:"user"`
+	}
+
+	input := map[string]string{"token": token}
+	err := c.call(ctx, http.MethodPost, "/v1/tokens/introspect", input, &data)
+	if errors.Is(err, errUnauthorized) || errors.Is(err, errNotFound) {
+		c.invalidTokens.Set(key, struct{}{})
+		return nil, access.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data.User == nil || data.User.UserID == "" {
+		return nil, errors.New("auth service returned no user for token")
+	}
+
+	c.tokens.Set(key, data.User)
+
+	return data.User, nil
+}
+
+// tokenCacheKey returns the key that the result of introspecting token is
+// cached under. A hash is used so that the tokens themselves are not kept in
+// memory.
+func tokenCacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// LookupToken implements the webapp.TokenStore interface.
+func (c *Client) LookupToken(ctx context.Context, token string) (webapp.Principal, error) {
+	user, err := c.Introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// PermissionsForPrincipal implements the webapp.PermissionStore interface. A
+// 404 response is taken to mean that the principal has no permissions.
+func (c *Client) PermissionsForPrincipal(ctx context.Context, principalID string) (access.Permissions, error) {
+	permissions, found := c.permissions.Get(principalID)
+	if found {
+		return permissions, nil
+	}
+
+	var data struct {
+		Permissions access.Permissions `json:"permissions"`
+	}
+
+	path := "/v1/users/" + url.PathEscape(principalID) + "/permissions"
+	err := c.call(ctx, http.MethodGet, path, nil, &data)
+	if errors.Is(err, errNotFound) {
+		data.Permissions = access.Permissions{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	c.permissions.Set(principalID, data.Permissions)
+
+	return data.Permissions, nil
+}
+
+// Invalidate removes any cached permissions for the principal with the given
+// ID so that changes to them take effect immediately.
+func (c *Client) Invalidate(principalID string) {
+	c.permissions.Delete(principalID)
+}
+
+// Authenticate is a middleware function that authenticates requests with
+// bearer tokens introspected by the auth service, storing the User in the
+// request context. See webapp.Authenticate for the details.
+func (c *Client) Authenticate(app *webapp.WebApp, next http.Handler) http.Handler {
+	return app.Authenticate(nil, c, next)
+}
+
+// call makes a request to the auth service through the circuit breaker and
+// decodes the data of a successful JSend response into dst. A 401 or 404
+// response is returned as errUnauthorized or errNotFound. Errors that suggest the auth
+// service is unhealthy, such as a failed connection or a 5xx response, wrap
+// access.ErrUnavailable and count as failures for the circuit breaker. Requests
+// abandoned because ctx was cancelled or reached its deadline do neither.
+func (c *Client) call(ctx context.Context, method, path string, body, dst any) error {
+	err := c.breaker.allow()
+	if err != nil {
+		return err
+	}
+
+	resp, jsend, err := jsonz.RequestJSendContext(ctx, method, c.baseURL+path, c.cfg.Timeout, body)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if err != nil {
+		// A request that the caller cancelled or that ran out of time says
+		// nothing about the health of the auth service.
+		if ctx.Err() != nil {
+			c.breaker.release()
+			return fmt.Errorf("auth service request failed: %w", err)
+		}
+
+		c.breaker.record(false)
+		return fmt.Errorf("auth service request failed: %w: %w", err, access.ErrUnavailable)
+	}
+
+	c.breaker.record(resp.StatusCode < http.StatusInternalServerError)
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return errUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		return errNotFound
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("auth service returned %s response with status %d: %w",
+			jsend.Status, resp.StatusCode, access.ErrUnavailable)
+	case jsend.Status != jsonz.JSendStatusSuccess:
+		return fmt.Errorf("auth service returned %s response with status %d", jsend.Status, resp.StatusCode)
+	}
+
+	err = json.Unmarshal(jsend.Data, dst)
+	if err != nil {
+		return fmt.Errorf("unable to decode auth service response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,378 @@
+package authclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+	"github.com/m5lapp/go-service-toolkit/config"
+	"github.com/m5lapp/go-service-toolkit/serialisation/jsonz"
+	"github.com/m5lapp/go-service-toolkit/webapp"
+)
+
+// authService is an httptest stand-in for an auth service. It knows a single
+// valid token, and can be made to fail every request with a server error.
+type authService struct {
+	mu       sync.Mutex
+	calls    int
+	failing  bool
+	released chan struct{}
+}
+
+func (s *authService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.calls++
+	failing := s.failing
+	released := s.released
+	s.mu.Unlock()
+
+	if released != nil {
+		<-released
+	}
+
+	if failing {
+		jsonz.WriteJSendError(w, http.StatusInternalServerError, nil, "auth service broken", nil, nil)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/tokens/introspect":
+		var input struct {
+			Token string `json:"token"`
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+
+		if input.Token != "valid" {
+			jsonz.WriteJSendFail(w, http.StatusUnauthorized, nil, map[string]string{"error": "invalid token"})
+			return
+		}
+
+		user := User{UserID: "user-1", Activated: true, Permissions: access.Permissions{"movies:read"}}
+		jsonz.WriteJSendSuccess(w, http.StatusOK, nil, map[string]any{"user": user})
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/users/user-1/permissions":
+		data := map[string]any{"permissions": access.Permissions{"movies:read", "movies:write"}}
+		jsonz.WriteJSendSuccess(w, http.StatusOK, nil, data)
+	default:
+		jsonz.WriteJSendFail(w, http.StatusNotFound, nil, map[string]string{"error": "not found"})
+	}
+}
+
+func (s *authService) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls
+}
+
+func (s *authService) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failing = failing
+}
+
+func newTestClient(t *testing.T, cfg config.AuthService) (*Client, *authService) {
+	t.Helper()
+
+	svc := &authService{}
+	srv := httptest.NewServer(svc)
+	t.Cleanup(srv.Close)
+
+	cfg.URL = srv.URL
+
+	return New(cfg), svc
+}
+
+func TestIntrospectCaching(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		cacheTTL  time.Duration
+		wantErr   error
+		wantCalls int
+	}{
+		{"valid token cached", "valid", time.Minute, nil, 1},
+		{"invalid token cached", "invalid", time.Minute, access.ErrInvalidToken, 1},
+		{"valid token with caching disabled", "valid", 0, nil, 3},
+		{"invalid token with caching disabled", "invalid", 0, access.ErrInvalidToken, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, svc := newTestClient(t, config.AuthService{CacheTTL: tt.cacheTTL})
+
+			for i := 0; i < 3; i++ {
+				user, err := client.Introspect(context.Background(), tt.token)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v; want %v", err, tt.wantErr)
+				}
+				if tt.wantErr == nil && user.ID() != "user-1" {
+					t.Fatalf("got user %q; want %q", user.ID(), "user-1")
+				}
+			}
+
+			if got := svc.callCount(); got != tt.wantCalls {
+				t.Errorf("got %d calls to the auth service; want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIntrospectInvalidTokensBounded(t *testing.T) {
+	client, svc := newTestClient(t, config.AuthService{CacheTTL: time.Minute})
+
+	_, err := client.Introspect(context.Background(), "valid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < maxCachedInvalidTokens+100; i++ {
+		_, err := client.Introspect(context.Background(), fmt.Sprintf("made-up-%d", i))
+		if !errors.Is(err, access.ErrInvalidToken) {
+			t.Fatalf("got error %v; want %v", err, access.ErrInvalidToken)
+		}
+	}
+
+	if got := client.invalidTokens.Len(); got != maxCachedInvalidTokens {
+		t.Errorf("got %d cached invalid tokens; want %d", got, maxCachedInvalidTokens)
+	}
+
+	// The made up tokens have not pushed the valid one out of the cache.
+	calls := svc.callCount()
+	_, err = client.Introspect(context.Background(), "valid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := svc.callCount(); got != calls {
+		t.Errorf("got %d calls to the auth service; want the valid token to still be cached", got-calls)
+	}
+}
+
+func TestPermissionsForPrincipal(t *testing.T) {
+	client, svc := newTestClient(t, config.AuthService{CacheTTL: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		permissions, err := client.PermissionsForPrincipal(context.Background(), "user-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !permissions.Include("movies:write") {
+			t.Fatalf("got permissions %v; want movies:write to be included", permissions)
+		}
+	}
+
+	if got := svc.callCount(); got != 1 {
+		t.Errorf("got %d calls to the auth service; want 1", got)
+	}
+
+	client.Invalidate("user-1")
+
+	_, err := client.PermissionsForPrincipal(context.Background(), "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := svc.callCount(); got != 2 {
+		t.Errorf("got %d calls to the auth service after Invalidate; want 2", got)
+	}
+}
+
+func TestPermissionsForPrincipalNotFound(t *testing.T) {
+	client, svc := newTestClient(t, config.AuthService{CacheTTL: time.Minute})
+
+	// The stand-in auth service responds with a 404 for any other user.
+	for i := 0; i < 2; i++ {
+		permissions, err := client.PermissionsForPrincipal(context.Background(), "user-2")
+		if err != nil {
+			t.Fatalf("got error %v; want no permissions", err)
+		}
+		if len(permissions) != 0 {
+			t.Fatalf("got permissions %v; want none", permissions)
+		}
+	}
+
+	if got := svc.callCount(); got != 1 {
+		t.Errorf("got %d calls to the auth service; want 1", got)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	cooldown := 50 * time.Millisecond
+	client, svc := newTestClient(t, config.AuthService{
+		BreakerThreshold: 2,
+		BreakerCooldown:  cooldown,
+	})
+	introspect := func() error {
+		_, err := client.Introspect(context.Background(), "valid")
+		return err
+	}
+
+	svc.setFailing(true)
+
+	// Closed: failures are passed through until the threshold is reached.
+	for i := 0; i < 2; i++ {
+		err := introspect()
+		if err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: got error %v; want an auth service failure", i, err)
+		}
+	}
+
+	// Open: calls are rejected without reaching the auth service.
+	err := introspect()
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, access.ErrUnavailable) {
+		t.Fatalf("got error %v; want ErrCircuitOpen", err)
+	}
+	if got := svc.callCount(); got != 2 {
+		t.Fatalf("got %d calls to the auth service whilst open; want 2", got)
+	}
+
+	// Half-open: after the cooldown, a failed trial call opens it again.
+	time.Sleep(cooldown)
+
+	err = introspect()
+	if err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got error %v; want the trial call to reach the auth service", err)
+	}
+	if err := introspect(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got error %v after a failed trial; want ErrCircuitOpen", err)
+	}
+
+	// Half-open: a successful trial call closes it again.
+	time.Sleep(cooldown)
+	svc.setFailing(false)
+
+	if err := introspect(); err != nil {
+		t.Fatalf("got error %v; want the trial call to succeed", err)
+	}
+	client.tokens.Delete(tokenCacheKey("valid"))
+	if err := introspect(); err != nil {
+		t.Fatalf("got error %v after a successful trial; want the breaker to be closed", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresCancelledRequests(t *testing.T) {
+	client, svc := newTestClient(t, config.AuthService{BreakerThreshold: 1})
+
+	svc.mu.Lock()
+	svc.released = make(chan struct{})
+	svc.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := client.Introspect(ctx, "valid")
+	if err == nil {
+		t.Fatal("got no error; want the request to be abandoned")
+	}
+
+	svc.mu.Lock()
+	close(svc.released)
+	svc.released = nil
+	svc.mu.Unlock()
+
+	_, err = client.Introspect(context.Background(), "valid")
+	if err != nil {
+		t.Fatalf("got error %v; want the breaker to still be closed", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	client, svc := newTestClient(t, config.AuthService{BreakerThreshold: 1, BreakerCooldown: time.Minute})
+
+	app := webapp.New(config.Server{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	handler := client.Authenticate(&app, app.RequirePermission("movies:write",
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		},
+	))
+	app.PermissionStore = client
+
+	tests := []struct {
+		name    string
+		token   string
+		failing bool
+		want    int
+	}{
+		{"valid token with permission from the store", "valid", false, http.StatusNoContent},
+		{"invalid token", "invalid", false, http.StatusUnauthorized},
+		{"auth service failing", "other", true, http.StatusServiceUnavailable},
+		{"circuit open", "another", true, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc.setFailing(tt.failing)
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/movies", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("got status %d; want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthServiceUnavailable(t *testing.T) {
+	svc := &authService{}
+	srv := httptest.NewServer(svc)
+	down := srv.URL
+	srv.Close()
+
+	tests := []struct {
+		name string
+		url  string
+		fail bool
+	}{
+		{"connection refused", down, false},
+		{"server error", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, svc := newTestClient(t, config.AuthService{})
+			if tt.url != "" {
+				client.baseURL = tt.url
+			}
+			svc.setFailing(tt.fail)
+
+			_, err := client.Introspect(context.Background(), "valid")
+			if !errors.Is(err, access.ErrUnavailable) {
+				t.Errorf("got error %v; want one wrapping access.ErrUnavailable", err)
+			}
+			if errors.Is(err, ErrCircuitOpen) {
+				t.Errorf("got error %v; want the auth service to have been called", err)
+			}
+		})
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	client := New(config.AuthService{Addr: "auth:4000"})
+
+	if client.baseURL != "http://auth:4000" {
+		t.Errorf("got base URL %q; want %q", client.baseURL, "http://auth:4000")
+	}
+	if client.breaker.threshold != config.DefaultAuthServiceBreakerThreshold {
+		t.Errorf("got breaker threshold %d; want %d", client.breaker.threshold, config.DefaultAuthServiceBreakerThreshold)
+	}
+	if client.cfg.Timeout != config.DefaultAuthServiceTimeout {
+		t.Errorf("got timeout %s; want %s", client.cfg.Timeout, config.DefaultAuthServiceTimeout)
+	}
+
+	client = New(config.AuthService{URL: "https://auth.example.com/"})
+	if client.baseURL != "https://auth.example.com" {
+		t.Errorf("got base URL %q; want %q", client.baseURL, "https://auth.example.com")
+	}
+}
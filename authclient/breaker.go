@@ -0,0 +1,75 @@
+package authclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+)
+
+// ErrCircuitOpen is returned instead of calling the auth service when it has
+// failed too many times in a row and is being given time to recover. It wraps
+// access.ErrUnavailable, so the webapp middlewares respond to it with a 503.
+var ErrCircuitOpen = fmt.Errorf("auth service circuit breaker is open: %w", access.ErrUnavailable)
+
+// breaker is a circuit breaker. It opens after threshold consecutive failures
+// and then rejects calls until cooldown has passed, at which point it lets a
+// single trial call through. If that succeeds, the breaker closes again;
+// otherwise it stays open for another cooldown.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// allow returns ErrCircuitOpen if a call should not be made.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+
+	if b.trial || time.Now().Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+
+	// Half-open: let this call through as a trial.
+	b.trial = true
+
+	return nil
+}
+
+// record records the outcome of a call that allow() permitted.
+func (b *breaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+
+	if success {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// release ends a call that allow() permitted without recording its outcome,
+// for when the call was abandoned by the caller rather than failed by the auth
+// service. If it was a trial call, then the next call becomes the trial.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
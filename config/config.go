@@ -6,88 +6,322 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 )
 
-// AuthService stores the configuration for an authentication service.
+// secretUsage is appended to the usage message of flags that set a Secret.
+const secretUsage = " (VALUE, secretfile:PATH or secretenv:NAME)"
+
+// flagName returns the given flag name with the prefix prepended to it and
+// separated by a hyphen. If prefix is empty, then name is returned unchanged.
+func flagName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "-" + name
+}
+
+// Formats for the access log.
+const (
+	AccessLogStructured = "structured"
+	AccessLogCombined   = "combined"
+)
+
+// AccessLog stores the configuration for the access log. A SampleRate of 1
+// logs every request and 0 logs none, although server errors are always
+// logged. Requests for any of the ExcludePaths are never logged. The Format is
+// either AccessLogStructured for one field per attribute, or AccessLogCombined
+// for a message in the Apache combined log format.
+type AccessLog struct {
+	Active       bool
+	SampleRate   float64
+	ExcludePaths []string
+	Format       string
+
+	prefix string
+}
+
+// Flags parses the flags for the access log.
+func (a *AccessLog) Flags() {
+	a.RegisterFlags(flag.CommandLine, "")
+}
+
+// RegisterFlags registers the flags for the access log onto fs with each flag
+// name prefixed by prefix. By default, every request is logged except for
+// those to the /health and /metrics endpoints.
+func (a *AccessLog) RegisterFlags(fs *flag.FlagSet, prefix string) {
+	a.prefix = prefix
+
+	fs.BoolVar(&a.Active, flagName(prefix, "access-log-active"), true, "Activate the access log")
+	fs.Float64Var(&a.SampleRate, flagName(prefix, "access-log-sample-rate"), 1,
+		"Fraction of requests to write to the access log (0-1)")
+
+	a.ExcludePaths = []string{"/health", "/metrics"}
+	fs.Var(&listValue{dst: &a.ExcludePaths}, flagName(prefix, "access-log-exclude"),
+		"Paths to leave out of the access log (space seperated)")
+
+	fs.StringVar(&a.Format, flagName(prefix, "access-log-format"), AccessLogStructured,
+		"Access log format (structured|combined)")
+}
+
+// Default values for the AuthService client.
+const (
+	DefaultAuthServiceTimeout          = 5 * time.Second
+	DefaultAuthServiceCacheTTL         = time.Minute
+	DefaultAuthServiceBreakerThreshold = 5
+	DefaultAuthServiceBreakerCooldown  = 30 * time.Second
+)
+
+// AuthService stores the configuration for an authentication service. It is
+// called at its URL, which includes the scheme so that HTTPS can be used, or
+// if that is not set, over plain HTTP at its Addr. The Timeout applies to each
+// request made to it and successful responses are cached for CacheTTL. After
+// BreakerThreshold consecutive failures, no more requests are made to it until
+// BreakerCooldown has passed.
 type AuthService struct {
-	Addr string
+	Addr             string
+	URL              string
+	Timeout          time.Duration
+	CacheTTL         time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	prefix string
 }
 
-// Flags parses the flags configured for an auth service. The parameters it
-// takes are the default values to use for the rps, burst and active flags
-// respectively.
+// Flags parses the flags configured for an auth service. The parameter it
+// takes is the default value to use for the auth-addr flag.
 func (a *AuthService) Flags(addr string) {
-	flag.StringVar(&a.Addr, "addr", addr,
+	a.RegisterFlags(flag.CommandLine, "", addr)
+}
+
+// RegisterFlags registers the flags for an auth service onto fs with each flag
+// name prefixed by prefix. The remaining parameters are the same as for Flags.
+func (a *AuthService) RegisterFlags(fs *flag.FlagSet, prefix, addr string) {
+	a.prefix = prefix
+
+	fs.StringVar(&a.Addr, flagName(prefix, "auth-addr"), addr,
 		"Auth service HTTP address in format: [HOST]:PORT")
+	fs.StringVar(&a.URL, flagName(prefix, "auth-url"), "",
+		"Auth service base URL, such as https://auth.example.com (overrides the address)")
+	fs.DurationVar(&a.Timeout, flagName(prefix, "auth-timeout"), DefaultAuthServiceTimeout,
+		"Auth service request timeout")
+	fs.DurationVar(&a.CacheTTL, flagName(prefix, "auth-cache-ttl"), DefaultAuthServiceCacheTTL,
+		"How long to cache auth service responses for (0 to disable)")
+	fs.IntVar(&a.BreakerThreshold, flagName(prefix, "auth-breaker-threshold"), DefaultAuthServiceBreakerThreshold,
+		"Consecutive auth service failures before requests to it are stopped")
+	fs.DurationVar(&a.BreakerCooldown, flagName(prefix, "auth-breaker-cooldown"), DefaultAuthServiceBreakerCooldown,
+		"How long to stop requests to the auth service for after too many failures")
+}
+
+// BaseURL returns the URL that the paths of the auth service's endpoints are
+// appended to, without a trailing slash.
+func (a *AuthService) BaseURL() string {
+	if a.URL != "" {
+		return strings.TrimSuffix(a.URL, "/")
+	}
+	return "http://" + a.Addr
 }
 
 // Cors stores the configuration for CORS (Cross-Origin Resource Sharing).
+//
+// Each of the TrustedOrigins is either an exact origin such as
+// https://www.example.com, a pattern with a single wildcard subdomain such as
+// https://*.example.com, or * to trust any origin. A MaxAge of zero means that
+// the Access-Control-Max-Age header is not sent.
 type Cors struct {
-	AllowMethods   []string
-	TrustedOrigins []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+	TrustedOrigins   []string
+
+	prefix string
 }
 
 // Flags parses the flags configured for CORS.
-// TODO: These two flags are not currently populated when called.
 func (c *Cors) Flags() {
-	flag.Func(
-		"cors-allow-methods",
-		"HTTP methods allowed for CORS requests (space seperated)",
-		func(val string) error {
-			allowedMethods := map[string]bool{
-				http.MethodDelete:  true,
-				http.MethodGet:     true,
-				http.MethodHead:    true,
-				http.MethodOptions: true,
-				http.MethodPatch:   true,
-				http.MethodPost:    true,
-				http.MethodPut:     true,
-			}
-
-			upper := strings.ToUpper(val)
-			methods := strings.Fields(upper)
-			sort.Strings(methods)
-
-			if len(methods) == 0 {
-				return fmt.Errorf("no HTTP methods supplied for cors-allow-methods")
-			}
-
-			for _, method := range methods {
-				_, ok := allowedMethods[method]
-				if !ok {
-					return fmt.Errorf("invalid HTTP method for CORS: %s", method)
-				}
-			}
-
-			c.AllowMethods = methods
-			return nil
-		},
-	)
-
-	flag.Func(
-		"cors-trusted-origins",
-		"Trusted CORS origins (space seperated)",
-		func(val string) error {
-			c.TrustedOrigins = strings.Fields(val)
-			return nil
-		},
-	)
-}
-
-// Limiter stores the configuration for a rate limiter.
+	c.RegisterFlags(flag.CommandLine, "")
+}
+
+// RegisterFlags registers the flags for CORS onto fs with each flag name
+// prefixed by prefix. By default, all of the standard HTTP methods and the
+// Authorization and Content-Type headers are allowed, but no origins are
+// trusted.
+func (c *Cors) RegisterFlags(fs *flag.FlagSet, prefix string) {
+	c.prefix = prefix
+
+	c.AllowMethods = []string{
+		http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodPatch, http.MethodPost, http.MethodPut,
+	}
+	fs.Var(&listValue{dst: &c.AllowMethods, parse: parseCorsMethods},
+		flagName(prefix, "cors-allow-methods"),
+		"HTTP methods allowed for CORS requests (space seperated)")
+
+	c.AllowHeaders = []string{"Authorization", "Content-Type"}
+	fs.Var(&listValue{dst: &c.AllowHeaders},
+		flagName(prefix, "cors-allow-headers"),
+		"Request headers allowed for CORS requests (space seperated)")
+
+	fs.Var(&listValue{dst: &c.ExposeHeaders},
+		flagName(prefix, "cors-expose-headers"),
+		"Response headers exposed to CORS requests (space seperated)")
+
+	fs.BoolVar(&c.AllowCredentials, flagName(prefix, "cors-allow-credentials"), false,
+		"Allow CORS requests to include credentials such as cookies")
+
+	fs.DurationVar(&c.MaxAge, flagName(prefix, "cors-max-age"), 0,
+		"Max time browsers may cache CORS preflight responses")
+
+	fs.Var(&listValue{dst: &c.TrustedOrigins},
+		flagName(prefix, "cors-trusted-origins"),
+		"Trusted CORS origins, e.g. https://*.example.com (space seperated)")
+}
+
+// parseCorsMethods parses the value of the cors-allow-methods flag.
+func parseCorsMethods(val string) ([]string, error) {
+	allowedMethods := map[string]bool{
+		http.MethodDelete:  true,
+		http.MethodGet:     true,
+		http.MethodHead:    true,
+		http.MethodOptions: true,
+		http.MethodPatch:   true,
+		http.MethodPost:    true,
+		http.MethodPut:     true,
+	}
+
+	upper := strings.ToUpper(val)
+	methods := strings.Fields(upper)
+	sort.Strings(methods)
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no HTTP methods supplied for cors-allow-methods")
+	}
+
+	for _, method := range methods {
+		_, ok := allowedMethods[method]
+		if !ok {
+			return nil, fmt.Errorf("invalid HTTP method for CORS: %s", method)
+		}
+	}
+
+	return methods, nil
+}
+
+// listValue is a flag.Value for a space separated list of strings stored in
+// dst. If parse is nil, then the list is split on whitespace.
+type listValue struct {
+	dst   *[]string
+	parse func(string) ([]string, error)
+}
+
+// String implements the flag.Value interface.
+func (l *listValue) String() string {
+	if l.dst == nil {
+		return ""
+	}
+	return strings.Join(*l.dst, " ")
+}
+
+// Set implements the flag.Value interface.
+func (l *listValue) Set(val string) error {
+	if l.parse == nil {
+		*l.dst = strings.Fields(val)
+		return nil
+	}
+
+	list, err := l.parse(val)
+	if err != nil {
+		return err
+	}
+
+	*l.dst = list
+	return nil
+}
+
+// Algorithms that a JWT can be signed with.
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+// JWT stores the configuration for validating JSON Web Tokens. Tokens signed
+// with HS256 are verified with the HMACSecret, and those signed with RS256 or
+// EdDSA are verified with the public keys in a JSON Web Key Set read from
+// JWKSFile or fetched from JWKSURL, which is refreshed every JWKSRefresh. Only
+// the listed Algorithms are accepted. If Issuer or Audience are set, then
+// tokens must have matching iss or aud claims. Leeway allows for clock skew
+// when checking the exp and nbf claims.
+type JWT struct {
+	Algorithms  []string
+	HMACSecret  Secret
+	JWKSFile    string
+	JWKSURL     string
+	JWKSRefresh time.Duration
+	Issuer      string
+	Audience    string
+	Leeway      time.Duration
+
+	prefix string
+}
+
+// Flags parses the flags for validating JWTs.
+func (j *JWT) Flags() {
+	j.RegisterFlags(flag.CommandLine, "")
+}
+
+// RegisterFlags registers the flags for validating JWTs onto fs with each flag
+// name prefixed by prefix. By default, only RS256 and EdDSA tokens are
+// accepted.
+func (j *JWT) RegisterFlags(fs *flag.FlagSet, prefix string) {
+	j.prefix = prefix
+
+	j.Algorithms = []string{JWTAlgorithmRS256, JWTAlgorithmEdDSA}
+	fs.Var(&listValue{dst: &j.Algorithms}, flagName(prefix, "jwt-algorithms"),
+		"Accepted JWT signing algorithms (HS256|RS256|EdDSA, space seperated)")
+	fs.Var(&j.HMACSecret, flagName(prefix, "jwt-hmac-secret"), "Secret for HS256 JWTs"+secretUsage)
+	fs.StringVar(&j.JWKSFile, flagName(prefix, "jwt-jwks-file"), "", "JWKS file path for RS256 and EdDSA JWTs")
+	fs.StringVar(&j.JWKSURL, flagName(prefix, "jwt-jwks-url"), "", "JWKS URL for RS256 and EdDSA JWTs")
+	fs.DurationVar(&j.JWKSRefresh, flagName(prefix, "jwt-jwks-refresh"), 15*time.Minute,
+		"How often to reload the JWKS")
+	fs.StringVar(&j.Issuer, flagName(prefix, "jwt-issuer"), "", "Required JWT issuer (iss claim)")
+	fs.StringVar(&j.Audience, flagName(prefix, "jwt-audience"), "", "Required JWT audience (aud claim)")
+	fs.DurationVar(&j.Leeway, flagName(prefix, "jwt-leeway"), 30*time.Second,
+		"Allowed clock skew when checking JWT expiry times")
+}
+
+// Limiter stores the configuration for a rate limiter. RPS and Burst are the
+// default limits for each client. Policies can override them for particular
+// routes and tiers of client; see LimiterPolicy.
 type Limiter struct {
-	RPS    float64
-	Burst  int
-	Active bool
+	RPS      float64
+	Burst    int
+	Active   bool
+	Policies []LimiterPolicy
+
+	prefix string
 }
 
 // Flags parses the flags configured for a rate limiter. The parameters it
 // takes are the default values to use for the rps, burst and active flags
 // respectively.
 func (l *Limiter) Flags(rps float64, burst int, active bool) {
-	flag.Float64Var(&l.RPS, "limiter-rps", rps, "Rate limiter max requests per second")
-	flag.IntVar(&l.Burst, "limiter-burst", burst, "Rate limiter max burst per second")
-	flag.BoolVar(&l.Active, "limiter-active", active, "Activate rate limiter")
+	l.RegisterFlags(flag.CommandLine, "", rps, burst, active)
+}
+
+// RegisterFlags registers the flags for a rate limiter onto fs with each flag
+// name prefixed by prefix. The remaining parameters are the same as for Flags.
+func (l *Limiter) RegisterFlags(fs *flag.FlagSet, prefix string, rps float64, burst int, active bool) {
+	l.prefix = prefix
+
+	fs.Float64Var(&l.RPS, flagName(prefix, "limiter-rps"), rps, "Rate limiter max requests per second")
+	fs.IntVar(&l.Burst, flagName(prefix, "limiter-burst"), burst, "Rate limiter max burst per second")
+	fs.BoolVar(&l.Active, flagName(prefix, "limiter-active"), active, "Activate rate limiter")
+	fs.Var(&policiesValue{dst: &l.Policies}, flagName(prefix, "limiter-policies"),
+		"Rate limiter policies in format: [METHOD@]PATH=RPS,BURST[,TIER] (space seperated)")
 }
 
 // MongoDB stores the configuration for a MongoDB NoSQL database.
@@ -95,38 +329,158 @@ type MongoDB struct {
 	Host       string
 	Schema     string
 	PrivateKey string
+
+	prefix string
 }
 
 // Flags parses the flags for a MongoDB database.
 func (m *MongoDB) Flags() {
-	flag.StringVar(&m.Host, "mongo-host", "", "MongoDB hostname")
-	flag.StringVar(&m.Schema, "mongo-schema", "", "MongoDB cluster name")
-	flag.StringVar(&m.PrivateKey, "mongo-key", "", "Private key path for MongoDB")
+	m.RegisterFlags(flag.CommandLine, "")
+}
+
+// RegisterFlags registers the flags for a MongoDB database onto fs with each
+// flag name prefixed by prefix.
+func (m *MongoDB) RegisterFlags(fs *flag.FlagSet, prefix string) {
+	m.prefix = prefix
+
+	fs.StringVar(&m.Host, flagName(prefix, "mongo-host"), "", "MongoDB hostname")
+	fs.StringVar(&m.Schema, flagName(prefix, "mongo-schema"), "", "MongoDB cluster name")
+	fs.StringVar(&m.PrivateKey, flagName(prefix, "mongo-key"), "", "Private key path for MongoDB")
+}
+
+// Proxy stores the configuration for working out the IP address of clients
+// that connect through reverse proxies or load balancers. The Header is only
+// trusted when a request comes from one of the TrustedProxies, which are CIDR
+// ranges or single IP addresses. It may be one of Forwarded (RFC 7239),
+// X-Forwarded-For and X-Real-IP, and must be the one that the proxies set, as
+// a client can send any of the others itself. If it is empty, then the address
+// of the direct peer is always used.
+type Proxy struct {
+	TrustedProxies []string
+	Header         string
+
+	prefix string
 }
 
-// Server stores the configuration for a web application server.
+// Flags parses the flags for trusted proxies.
+func (p *Proxy) Flags() {
+	p.RegisterFlags(flag.CommandLine, "")
+}
+
+// RegisterFlags registers the flags for trusted proxies onto fs with each flag
+// name prefixed by prefix. By default, no proxies are trusted.
+func (p *Proxy) RegisterFlags(fs *flag.FlagSet, prefix string) {
+	p.prefix = prefix
+
+	fs.Var(&listValue{dst: &p.TrustedProxies}, flagName(prefix, "proxy-trusted"),
+		"Trusted proxy CIDR ranges or IP addresses (space seperated)")
+	fs.StringVar(&p.Header, flagName(prefix, "proxy-header"), "X-Forwarded-For",
+		"Header to read the client IP from when set by a trusted proxy (Forwarded|X-Forwarded-For|X-Real-IP)")
+}
+
+// Default values for the Server timeouts.
+const (
+	DefaultIdleTimeout     = 60 * time.Second
+	DefaultReadTimeout     = 10 * time.Second
+	DefaultWriteTimeout    = 30 * time.Second
+	DefaultShutdownTimeout = 20 * time.Second
+)
+
+// Server stores the configuration for a web application server. If TLSCertFile
+// and TLSKeyFile are set, then the server uses HTTPS. If TLSClientCAFile is
+// also set, then clients must present a certificate signed by one of the CAs in
+// it (mutual TLS). The AdminToken protects the administrative endpoints, such as
+// the one for changing the log level.
 type Server struct {
-	Addr string
-	Env  string
+	Addr          string
+	Env           string
+	LogLevel      string
+	LogFormat     string
+	ErrorLogLevel string
+
+	IdleTimeout       time.Duration
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	ShutdownTimeout   time.Duration
+	MaxHeaderBytes    int
+
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+
+	AdminToken Secret
+
+	prefix string
 }
 
 // Flags parses the flags for a web application server. The parameter is
 // for the default server address.
 func (s *Server) Flags(addr string) {
-	flag.StringVar(&s.Addr, "addr", addr, "HTTP address in format: [HOST]:PORT")
-	flag.StringVar(&s.Env, "env", "development", "Environment (development|staging|production)")
+	s.RegisterFlags(flag.CommandLine, "", addr)
+}
+
+// RegisterFlags registers the flags for a web application server onto fs with
+// each flag name prefixed by prefix. The remaining parameters are the same as
+// for Flags.
+func (s *Server) RegisterFlags(fs *flag.FlagSet, prefix, addr string) {
+	s.prefix = prefix
+
+	fs.StringVar(&s.Addr, flagName(prefix, "addr"), addr, "HTTP address in format: [HOST]:PORT")
+	fs.StringVar(&s.Env, flagName(prefix, "env"), EnvDevelopment, "Environment (development|staging|production)")
+	fs.StringVar(&s.LogLevel, flagName(prefix, "log-level"), "info", "Minimum log level (debug|info|warn|error)")
+	fs.StringVar(&s.LogFormat, flagName(prefix, "log-format"), LogFormatJSON, "Log format (json|text)")
+	fs.StringVar(&s.ErrorLogLevel, flagName(prefix, "error-log-level"), "error",
+		"Level to log the HTTP server's internal errors at, such as TLS handshake failures (debug|info|warn|error)")
+
+	fs.DurationVar(&s.IdleTimeout, flagName(prefix, "idle-timeout"), DefaultIdleTimeout,
+		"Max time to wait for the next request on a keep-alive connection")
+	fs.DurationVar(&s.ReadTimeout, flagName(prefix, "read-timeout"), DefaultReadTimeout,
+		"Max time to read an entire request, including the body")
+	fs.DurationVar(&s.ReadHeaderTimeout, flagName(prefix, "read-header-timeout"), 0,
+		"Max time to read request headers (defaults to read-timeout if 0)")
+	fs.DurationVar(&s.WriteTimeout, flagName(prefix, "write-timeout"), DefaultWriteTimeout,
+		"Max time to write a response")
+	fs.DurationVar(&s.ShutdownTimeout, flagName(prefix, "shutdown-timeout"), DefaultShutdownTimeout,
+		"Max time to wait for in-flight requests to complete when shutting down")
+	fs.IntVar(&s.MaxHeaderBytes, flagName(prefix, "max-header-bytes"), http.DefaultMaxHeaderBytes,
+		"Max size of request headers in bytes")
+
+	fs.StringVar(&s.TLSCertFile, flagName(prefix, "tls-cert-file"), "", "TLS certificate file path")
+	fs.StringVar(&s.TLSKeyFile, flagName(prefix, "tls-key-file"), "", "TLS private key file path")
+	fs.StringVar(&s.TLSClientCAFile, flagName(prefix, "tls-client-ca-file"), "",
+		"CA certificates file path for verifying client certificates (enables mTLS)")
+
+	fs.Var(&s.AdminToken, flagName(prefix, "admin-token"),
+		"Token for the /admin endpoints in an X-Admin-Token header (disabled if empty)"+secretUsage)
+}
+
+// TLSEnabled returns true if the server has been configured to use TLS.
+func (s *Server) TLSEnabled() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
 }
 
 // Service stores the configuration for an external service that can be called.
 type Service struct {
 	Addr string
+
+	name string
 }
 
 // Flags parses the flags for an external service. The parameters are the name
 // and description to use for the flag.
-func (s *Service) Flags(flagName, flagDesc string) {
+func (s *Service) Flags(name, flagDesc string) {
+	s.RegisterFlags(flag.CommandLine, "", name, flagDesc)
+}
+
+// RegisterFlags registers the flag for an external service onto fs with the
+// flag name prefixed by prefix. The remaining parameters are the same as for
+// Flags.
+func (s *Service) RegisterFlags(fs *flag.FlagSet, prefix, name, flagDesc string) {
+	s.name = flagName(prefix, name)
+
 	desc := fmt.Sprintf(flagDesc, "in format: PROTOCOL://HOST[:POST]")
-	flag.StringVar(&s.Addr, flagName, "", desc)
+	fs.StringVar(&s.Addr, s.name, "", desc)
 }
 
 // Smtp stores the configuration for an SMTP server connection.
@@ -134,36 +488,106 @@ type Smtp struct {
 	Host     string
 	Port     int
 	Username string
-	Password string
+	Password Secret
 	Sender   string
+
+	prefix string
 }
 
 // Flags parses the flags for an SMTP server connection.
 func (s *Smtp) Flags(host, sender string) {
-	flag.StringVar(&s.Host, "smtp-host", host, "SMTP host")
-	flag.IntVar(&s.Port, "smtp-port", 25, "SMTP port")
-	flag.StringVar(&s.Username, "smtp-username", "", "SMTP username")
-	flag.StringVar(&s.Password, "smtp-password", "", "SMTP password")
-	flag.StringVar(&s.Sender, "smtp-sender", sender,
+	s.RegisterFlags(flag.CommandLine, "", host, sender)
+}
+
+// RegisterFlags registers the flags for an SMTP server connection onto fs with
+// each flag name prefixed by prefix. The remaining parameters are the same as
+// for Flags.
+func (s *Smtp) RegisterFlags(fs *flag.FlagSet, prefix, host, sender string) {
+	s.prefix = prefix
+
+	fs.StringVar(&s.Host, flagName(prefix, "smtp-host"), host, "SMTP host")
+	fs.IntVar(&s.Port, flagName(prefix, "smtp-port"), 25, "SMTP port")
+	fs.StringVar(&s.Username, flagName(prefix, "smtp-username"), "", "SMTP username")
+	fs.Var(&s.Password, flagName(prefix, "smtp-password"), "SMTP password"+secretUsage)
+	fs.StringVar(&s.Sender, flagName(prefix, "smtp-sender"), sender,
 		"SMTP sender, format: Name <email@address.com>")
 }
 
 // SqlDB stores the configuration for a SQL database.
 type SqlDB struct {
 	Driver       string
-	DSN          string
+	DSN          Secret
 	MaxOpenConns int
 	MaxIdleConns int
 	MaxIdleTime  string
+
+	prefix string
 }
 
 // Flags parses the flags for a SQL database. The parameters it takes are
 // for the default max open connections, max idle connections and max idle
 // connection times respectively.
 func (s *SqlDB) Flags(driver string, open, idle int, idleTime string) {
-	flag.StringVar(&s.Driver, "db-driver-name", driver, "Database driver name")
-	flag.StringVar(&s.DSN, "db-dsn", "", "Database DSN (Data Source Name)")
-	flag.IntVar(&s.MaxOpenConns, "db-max-open-conns", open, "Database max open connections")
-	flag.IntVar(&s.MaxIdleConns, "db-max-idle-conns", idle, "Database max idle connections")
-	flag.StringVar(&s.MaxIdleTime, "db-max-idle-time", idleTime, "Database max connection idle time (time.Duration)")
+	s.RegisterFlags(flag.CommandLine, "", driver, open, idle, idleTime)
+}
+
+// RegisterFlags registers the flags for a SQL database onto fs with each flag
+// name prefixed by prefix, so that a service can be configured to use more
+// than one database. For example, the prefix "billing" results in the flags
+// -billing-db-dsn, -billing-db-driver-name and so on. The remaining parameters
+// are the same as for Flags.
+func (s *SqlDB) RegisterFlags(fs *flag.FlagSet, prefix, driver string, open, idle int, idleTime string) {
+	s.prefix = prefix
+
+	fs.StringVar(&s.Driver, flagName(prefix, "db-driver-name"), driver, "Database driver name")
+	fs.Var(&s.DSN, flagName(prefix, "db-dsn"), "Database DSN (Data Source Name)"+secretUsage)
+	fs.IntVar(&s.MaxOpenConns, flagName(prefix, "db-max-open-conns"), open, "Database max open connections")
+	fs.IntVar(&s.MaxIdleConns, flagName(prefix, "db-max-idle-conns"), idle, "Database max idle connections")
+	fs.StringVar(&s.MaxIdleTime, flagName(prefix, "db-max-idle-time"), idleTime, "Database max connection idle time (time.Duration)")
+}
+
+// Exporters for the Tracing configuration.
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+// Tracing stores the configuration for OpenTelemetry distributed tracing. The
+// Exporter is one of TracingExporterNone, TracingExporterStdout or
+// TracingExporterOTLP. The OTLPEndpoint is the HOST:PORT of an OTLP/HTTP
+// collector, and a SampleRatio of 1 traces every request that does not already
+// carry a sampling decision from its caller.
+type Tracing struct {
+	Exporter     string
+	ServiceName  string
+	OTLPEndpoint string
+	OTLPInsecure bool
+	SampleRatio  float64
+
+	prefix string
+}
+
+// Flags parses the flags for tracing. The parameter it takes is the default
+// service name to report spans under.
+func (t *Tracing) Flags(serviceName string) {
+	t.RegisterFlags(flag.CommandLine, "", serviceName)
+}
+
+// RegisterFlags registers the flags for tracing onto fs with each flag name
+// prefixed by prefix. The remaining parameters are the same as for Flags. By
+// default, tracing is turned off.
+func (t *Tracing) RegisterFlags(fs *flag.FlagSet, prefix, serviceName string) {
+	t.prefix = prefix
+
+	fs.StringVar(&t.Exporter, flagName(prefix, "tracing-exporter"), TracingExporterNone,
+		"Tracing exporter (none|stdout|otlp)")
+	fs.StringVar(&t.ServiceName, flagName(prefix, "tracing-service-name"), serviceName,
+		"Service name to report spans under")
+	fs.StringVar(&t.OTLPEndpoint, flagName(prefix, "tracing-otlp-endpoint"), "localhost:4318",
+		"OTLP/HTTP collector address in format: HOST:PORT")
+	fs.BoolVar(&t.OTLPInsecure, flagName(prefix, "tracing-otlp-insecure"), false,
+		"Connect to the OTLP collector without TLS")
+	fs.Float64Var(&t.SampleRatio, flagName(prefix, "tracing-sample-ratio"), 1,
+		"Fraction of new traces to sample (0-1)")
 }
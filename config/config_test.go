@@ -0,0 +1,36 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestAuthServiceFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	// The auth service flags must not collide with those of the server.
+	var server Server
+	var auth AuthService
+	server.RegisterFlags(fs, "", ":4000")
+	auth.RegisterFlags(fs, "", "auth:4000")
+
+	err := fs.Parse([]string{"-addr", ":8080", "-auth-url", "https://auth.example.com", "-auth-breaker-threshold", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if server.Addr != ":8080" {
+		t.Errorf("got server address %q; want %q", server.Addr, ":8080")
+	}
+	if auth.Addr != "auth:4000" {
+		t.Errorf("got auth service address %q; want %q", auth.Addr, "auth:4000")
+	}
+	if auth.BaseURL() != "https://auth.example.com" {
+		t.Errorf("got auth service base URL %q; want %q", auth.BaseURL(), "https://auth.example.com")
+	}
+	if auth.BreakerThreshold != 3 {
+		t.Errorf("got breaker threshold %d; want 3", auth.BreakerThreshold)
+	}
+}
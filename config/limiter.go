@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LimiterPolicy overrides the default rate limits of a Limiter for requests
+// that match its Method, Path and Tier.
+//
+// An empty Method matches any method. Path is matched against the request path
+// a segment at a time: a segment beginning with a colon, such as :id, matches
+// any single segment and a final * matches the remainder of the path, so
+// /v1/movies/:id matches /v1/movies/42 and /v1/* matches everything under
+// /v1/, but not /v1 itself. An empty Tier matches clients of any tier,
+// otherwise it must equal the tier of the client, such as free or paid.
+//
+// A policy is written in the format [METHOD@]PATH=RPS,BURST[,TIER], for
+// example POST@/v1/tokens=0.1,3 or /v1/*=50,100,paid.
+type LimiterPolicy struct {
+	Method string
+	Path   string
+	RPS    float64
+	Burst  int
+	Tier   string
+}
+
+// ParseLimiterPolicy parses a LimiterPolicy from a string in the format
+// [METHOD@]PATH=RPS,BURST[,TIER].
+func ParseLimiterPolicy(s string) (LimiterPolicy, error) {
+	var p LimiterPolicy
+
+	route, limits, found := strings.Cut(s, "=")
+	if !found {
+		return p, fmt.Errorf("invalid rate limiter policy %q: missing =", s)
+	}
+
+	method, path, found := strings.Cut(route, "@")
+	if !found {
+		method, path = "", route
+	}
+	if path == "" {
+		return p, fmt.Errorf("invalid rate limiter policy %q: missing path", s)
+	}
+
+	fields := strings.Split(limits, ",")
+	if len(fields) < 2 || len(fields) > 3 {
+		return p, fmt.Errorf("invalid rate limiter policy %q: expected RPS,BURST[,TIER]", s)
+	}
+
+	rps, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return p, fmt.Errorf("invalid rate limiter policy %q: invalid RPS", s)
+	}
+
+	burst, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return p, fmt.Errorf("invalid rate limiter policy %q: invalid burst", s)
+	}
+
+	p = LimiterPolicy{
+		Method: strings.ToUpper(method),
+		Path:   path,
+		RPS:    rps,
+		Burst:  burst,
+	}
+	if len(fields) == 3 {
+		p.Tier = fields[2]
+	}
+
+	return p, nil
+}
+
+// String returns the policy in the format accepted by ParseLimiterPolicy.
+func (p LimiterPolicy) String() string {
+	var b strings.Builder
+
+	if p.Method != "" {
+		b.WriteString(p.Method + "@")
+	}
+	b.WriteString(p.Path)
+	b.WriteString("=" + strconv.FormatFloat(p.RPS, 'f', -1, 64))
+	b.WriteString("," + strconv.Itoa(p.Burst))
+	if p.Tier != "" {
+		b.WriteString("," + p.Tier)
+	}
+
+	return b.String()
+}
+
+// Matches checks if the policy applies to a request with the given method and
+// path from a client in the given tier.
+func (p LimiterPolicy) Matches(method, path, tier string) bool {
+	if p.Method != "" && !strings.EqualFold(p.Method, method) {
+		return false
+	}
+	if p.Tier != "" && p.Tier != tier {
+		return false
+	}
+
+	patternSegs := strings.Split(strings.Trim(p.Path, "/"), "/")
+	pathSegs := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, seg := range patternSegs {
+		if seg == "*" && i == len(patternSegs)-1 {
+			// The * must match something, so /v1/* matches /v1/ and anything
+			// below it but not /v1 itself.
+			return i < len(pathSegs) || strings.HasSuffix(path, "/")
+		}
+		if i >= len(pathSegs) {
+			return false
+		}
+		if strings.HasPrefix(seg, ":") && pathSegs[i] != "" {
+			continue
+		}
+		if seg != pathSegs[i] {
+			return false
+		}
+	}
+
+	return len(patternSegs) == len(pathSegs)
+}
+
+// Policy returns the first of the Limiter's Policies that matches a request
+// with the given method and path from a client in the given tier. If none of
+// them match, then a policy with the Limiter's default RPS and Burst is
+// returned along with false.
+func (l *Limiter) Policy(method, path, tier string) (LimiterPolicy, bool) {
+	for _, p := range l.Policies {
+		if p.Matches(method, path, tier) {
+			return p, true
+		}
+	}
+
+	return LimiterPolicy{Path: "/*", RPS: l.RPS, Burst: l.Burst}, false
+}
+
+// policiesValue is a flag.Value for a space separated list of LimiterPolicy
+// values.
+type policiesValue struct {
+	dst *[]LimiterPolicy
+}
+
+// String implements the flag.Value interface.
+func (v *policiesValue) String() string {
+	if v.dst == nil {
+		return ""
+	}
+
+	policies := make([]string, len(*v.dst))
+	for i, p := range *v.dst {
+		policies[i] = p.String()
+	}
+
+	return strings.Join(policies, " ")
+}
+
+// Set implements the flag.Value interface.
+func (v *policiesValue) Set(val string) error {
+	var policies []LimiterPolicy
+
+	for _, field := range strings.Fields(val) {
+		p, err := ParseLimiterPolicy(field)
+		if err != nil {
+			return err
+		}
+		policies = append(policies, p)
+	}
+
+	*v.dst = policies
+	return nil
+}
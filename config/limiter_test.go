@@ -0,0 +1,108 @@
+package config
+
+import "testing"
+
+func TestParseLimiterPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    LimiterPolicy
+		wantErr bool
+	}{
+		{"path only", "/v1/movies=2,4", LimiterPolicy{Path: "/v1/movies", RPS: 2, Burst: 4}, false},
+		{"method", "post@/v1/tokens=0.1,3", LimiterPolicy{Method: "POST", Path: "/v1/tokens", RPS: 0.1, Burst: 3}, false},
+		{"tier", "/v1/*=50,100,paid", LimiterPolicy{Path: "/v1/*", RPS: 50, Burst: 100, Tier: "paid"}, false},
+		{"missing =", "/v1/movies", LimiterPolicy{}, true},
+		{"missing path", "GET@=2,4", LimiterPolicy{}, true},
+		{"missing burst", "/v1/movies=2", LimiterPolicy{}, true},
+		{"too many fields", "/v1/movies=2,4,paid,extra", LimiterPolicy{}, true},
+		{"invalid RPS", "/v1/movies=fast,4", LimiterPolicy{}, true},
+		{"invalid burst", "/v1/movies=2,1.5", LimiterPolicy{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParseLimiterPolicy(tt.s)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got policy %v and no error; want an error", p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p != tt.want {
+				t.Errorf("got policy %+v; want %+v", p, tt.want)
+			}
+			if got, _ := ParseLimiterPolicy(p.String()); got != p {
+				t.Errorf("got policy %+v after a round trip through %q; want %+v", got, p.String(), p)
+			}
+		})
+	}
+}
+
+func TestLimiterPolicyMatches(t *testing.T) {
+	tests := []struct {
+		policy string
+		method string
+		path   string
+		tier   string
+		want   bool
+	}{
+		{"/v1/movies=1,1", "GET", "/v1/movies", "", true},
+		{"/v1/movies=1,1", "GET", "/v1/movies/", "", true},
+		{"/v1/movies=1,1", "GET", "/v1/movies/42", "", false},
+		{"/v1/movies=1,1", "GET", "/v1", "", false},
+		{"/v1/movies/:id=1,1", "GET", "/v1/movies/42", "", true},
+		{"/v1/movies/:id=1,1", "GET", "/v1/movies", "", false},
+		{"/v1/movies/:id=1,1", "GET", "/v1/movies/42/reviews", "", false},
+		{"/v1/*=1,1", "GET", "/v1/movies", "", true},
+		{"/v1/*=1,1", "GET", "/v1/movies/42/reviews", "", true},
+		{"/v1/*=1,1", "GET", "/v1/", "", true},
+		{"/v1/*=1,1", "GET", "/v1", "", false},
+		{"/v1/*=1,1", "GET", "/v2/movies", "", false},
+		{"/*=1,1", "GET", "/", "", true},
+		{"/*=1,1", "GET", "/v1/movies", "", true},
+		{"POST@/v1/tokens=1,1", "POST", "/v1/tokens", "", true},
+		{"POST@/v1/tokens=1,1", "post", "/v1/tokens", "", true},
+		{"POST@/v1/tokens=1,1", "GET", "/v1/tokens", "", false},
+		{"/v1/*=1,1,paid", "GET", "/v1/movies", "paid", true},
+		{"/v1/*=1,1,paid", "GET", "/v1/movies", "free", false},
+		{"/v1/*=1,1,paid", "GET", "/v1/movies", "", false},
+	}
+
+	for _, tt := range tests {
+		p, err := ParseLimiterPolicy(tt.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Matches(tt.method, tt.path, tt.tier); got != tt.want {
+			t.Errorf("got %t for %s %s in tier %q with policy %s; want %t",
+				got, tt.method, tt.path, tt.tier, tt.policy, tt.want)
+		}
+	}
+}
+
+func TestLimiterPolicy(t *testing.T) {
+	l := &Limiter{RPS: 2, Burst: 4, Policies: []LimiterPolicy{
+		{Method: "POST", Path: "/v1/tokens", RPS: 0.1, Burst: 3},
+		{Path: "/v1/*", RPS: 50, Burst: 100, Tier: "paid"},
+	}}
+
+	p, ok := l.Policy("POST", "/v1/tokens", "paid")
+	if !ok || p != l.Policies[0] {
+		t.Errorf("got policy %v and %t; want %v and true", p, ok, l.Policies[0])
+	}
+
+	p, ok = l.Policy("GET", "/v1/movies", "paid")
+	if !ok || p != l.Policies[1] {
+		t.Errorf("got policy %v and %t; want %v and true", p, ok, l.Policies[1])
+	}
+
+	want := LimiterPolicy{Path: "/*", RPS: 2, Burst: 4}
+	p, ok = l.Policy("GET", "/v1/movies", "free")
+	if ok || p != want {
+		t.Errorf("got policy %v and %t; want %v and false", p, ok, want)
+	}
+}
@@ -0,0 +1,358 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultEnvPrefix is the prefix used for environment variables by a Loader
+// when no other prefix has been set.
+const DefaultEnvPrefix = "APP_"
+
+// Source identifies where the value of a configuration setting came from.
+type Source int
+
+const (
+	SourceDefault Source = iota
+	SourceFile
+	SourceEnv
+	SourceFlag
+)
+
+// String implements the fmt.Stringer interface.
+func (s Source) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	default:
+		return "unknown"
+	}
+}
+
+// Report maps the name of each registered flag to the Source that its current
+// value was set from. It never contains the values themselves so that it is
+// safe to log.
+type Report map[string]Source
+
+// String returns the Report as a sorted list of name=source pairs, one per
+// line.
+func (r Report) String() string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%s\n", name, r[name])
+	}
+
+	return b.String()
+}
+
+// Loader populates the flags registered by the Flags() methods of the structs
+// in this package from a number of layered sources. In order of increasing
+// precedence, these are:
+//
+//  1. The default values the flags were registered with.
+//  2. A YAML, JSON or TOML configuration file.
+//  3. Environment variables.
+//  4. Command line flags.
+//
+// Each setting is known by its flag name in every source. In a file, the key
+// db-max-open-conns (or db_max_open_conns) sets the -db-max-open-conns flag,
+// as does a nested db: {max-open-conns: 25} mapping. In the environment, the
+// same flag is set by the variable APP_DB_MAX_OPEN_CONNS. List values such as
+// the CORS trusted origins can be given as either a space separated string or
+// as a list.
+type Loader struct {
+	// FlagSet is the set of flags to populate. If it is nil, then the global
+	// flag.CommandLine is used.
+	FlagSet *flag.FlagSet
+	// File is the path to an optional configuration file. The format is
+	// determined by its extension: .yaml, .yml, .json or .toml. If it is empty,
+	// the path in the APP_CONFIG_FILE environment variable is used, if set.
+	File string
+	// EnvPrefix is the prefix for environment variables. If it is empty,
+	// DefaultEnvPrefix is used.
+	EnvPrefix string
+}
+
+// Load populates the FlagSet from each of the configured sources in order of
+// precedence. The args parameter should be the command line arguments without
+// the program name, usually os.Args[1:]. A Report of where each value came
+// from is returned on success.
+//
+// Load can be called again to pick up changes to the file or environment. Any
+// value no longer set by one of the sources is reset to its default. When
+// reloading the configuration of a running service, it must be called on a
+// Loader for a new FlagSet with new config structs registered on it, not the
+// live ones: Load sets every flag in turn, which would race with the
+// goroutines reading the running values and leave them half updated if the
+// new configuration turns out to be invalid. See webapp.OnReload.
+func (l *Loader) Load(args []string) (Report, error) {
+	fs := l.flagSet()
+	report := make(Report)
+
+	// Work out which flags were given on the command line first so that the
+	// lower precedence sources do not need to set them.
+	cmdLine := commandLineFlags(fs, args)
+
+	// Reset every other flag to its default so that a value which has been
+	// removed from a source since the last call to Load() is not kept.
+	var resetErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		report[f.Name] = SourceDefault
+
+		if resetErr != nil || cmdLine[f.Name] || f.Value.String() == f.DefValue {
+			return
+		}
+
+		err := f.Value.Set(f.DefValue)
+		if err != nil {
+			resetErr = fmt.Errorf("unable to reset %q to its default value: %w", f.Name, err)
+		}
+	})
+	if resetErr != nil {
+		return nil, resetErr
+	}
+
+	fileValues, err := l.readFile()
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range fileValues {
+		if fs.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown configuration key %q in %s", name, l.file())
+		}
+		if cmdLine[name] {
+			continue
+		}
+
+		err := fs.Set(name, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %q in %s: %w", name, l.file(), err)
+		}
+		report[name] = SourceFile
+	}
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if envErr != nil || cmdLine[f.Name] {
+			return
+		}
+
+		key := l.EnvName(f.Name)
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			return
+		}
+
+		err := fs.Set(f.Name, value)
+		if err != nil {
+			envErr = fmt.Errorf("invalid value for environment variable %s: %w", key, err)
+			return
+		}
+		report[f.Name] = SourceEnv
+	})
+	if envErr != nil {
+		return nil, envErr
+	}
+
+	err = fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range cmdLine {
+		report[name] = SourceFlag
+	}
+
+	return report, nil
+}
+
+// EnvName returns the name of the environment variable that sets the flag with
+// the given name.
+func (l *Loader) EnvName(flagName string) string {
+	prefix := l.EnvPrefix
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+
+	name := strings.ToUpper(flagName)
+	name = strings.NewReplacer("-", "_", ".", "_").Replace(name)
+
+	return prefix + name
+}
+
+func (l *Loader) flagSet() *flag.FlagSet {
+	if l.FlagSet == nil {
+		return flag.CommandLine
+	}
+	return l.FlagSet
+}
+
+func (l *Loader) file() string {
+	if l.File != "" {
+		return l.File
+	}
+	return os.Getenv(l.EnvName("config-file"))
+}
+
+// readFile reads and decodes the configuration file, if there is one, and
+// returns its contents as a map of flag names to flag values.
+func (l *Loader) readFile() (map[string]string, error) {
+	path := l.file()
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]any)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&raw)
+		if err == io.EOF {
+			err = nil
+		}
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported configuration file format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	err = flatten(values, "", raw)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+// flatten walks the nested map m, joining nested keys with a hyphen so that
+// they match flag names, and stores the string form of each value in dst.
+func flatten(dst map[string]string, prefix string, m map[string]any) error {
+	for k, v := range m {
+		key := strings.ReplaceAll(strings.ToLower(k), "_", "-")
+		if prefix != "" {
+			key = prefix + "-" + key
+		}
+
+		switch val := v.(type) {
+		case map[string]any:
+			err := flatten(dst, key, val)
+			if err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, len(val))
+			for i := range val {
+				s, err := scalarString(val[i])
+				if err != nil {
+					return fmt.Errorf("key %q: %w", key, err)
+				}
+				items[i] = s
+			}
+			dst[key] = strings.Join(items, " ")
+		default:
+			s, err := scalarString(val)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", key, err)
+			}
+			dst[key] = s
+		}
+	}
+
+	return nil
+}
+
+// scalarString converts a single decoded configuration value into the string
+// form expected by flag.Value.Set().
+func scalarString(v any) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case int:
+		return strconv.Itoa(val), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case uint64:
+		return strconv.FormatUint(val, 10), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case json.Number:
+		return val.String(), nil
+	case fmt.Stringer:
+		return val.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// recorder is a flag.Value that discards whatever it is set to. It is used to
+// find out which flags are present in a set of arguments without changing the
+// real values.
+type recorder struct {
+	isBool bool
+}
+
+func (r recorder) String() string   { return "" }
+func (r recorder) Set(string) error { return nil }
+func (r recorder) IsBoolFlag() bool { return r.isBool }
+
+// commandLineFlags returns the names of the flags in fs that are set in args.
+func commandLineFlags(fs *flag.FlagSet, args []string) map[string]bool {
+	shadow := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	shadow.SetOutput(io.Discard)
+
+	fs.VisitAll(func(f *flag.Flag) {
+		bf, ok := f.Value.(interface{ IsBoolFlag() bool })
+		shadow.Var(recorder{isBool: ok && bf.IsBoolFlag()}, f.Name, "")
+	})
+
+	// Any errors will be reported when the real FlagSet is parsed.
+	_ = shadow.Parse(args)
+
+	names := make(map[string]bool)
+	shadow.Visit(func(f *flag.Flag) {
+		names[f.Name] = true
+	})
+
+	return names
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoaderPrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		env        map[string]string
+		args       []string
+		wantEnv    string
+		wantLevel  string
+		wantIdle   time.Duration
+		wantSource map[string]Source
+	}{
+		{
+			name:      "defaults",
+			wantEnv:   "development",
+			wantLevel: "info",
+			wantIdle:  time.Minute,
+			wantSource: map[string]Source{
+				"env": SourceDefault, "log-level": SourceDefault, "idle-timeout": SourceDefault,
+			},
+		},
+		{
+			name:      "file over defaults",
+			file:      "env: staging\nlog-level: warn\nidle-timeout: 90s\n",
+			wantEnv:   "staging",
+			wantLevel: "warn",
+			wantIdle:  90 * time.Second,
+			wantSource: map[string]Source{
+				"env": SourceFile, "log-level": SourceFile, "idle-timeout": SourceFile,
+			},
+		},
+		{
+			name:      "env over file",
+			file:      "env: staging\nlog-level: warn\n",
+			env:       map[string]string{"TEST_LOG_LEVEL": "error"},
+			wantEnv:   "staging",
+			wantLevel: "error",
+			wantIdle:  time.Minute,
+			wantSource: map[string]Source{
+				"env": SourceFile, "log-level": SourceEnv, "idle-timeout": SourceDefault,
+			},
+		},
+		{
+			name:      "flags over env and file",
+			file:      "env: staging\nlog-level: warn\n",
+			env:       map[string]string{"TEST_ENV": "production", "TEST_LOG_LEVEL": "error"},
+			args:      []string{"-log-level", "debug"},
+			wantEnv:   "production",
+			wantLevel: "debug",
+			wantIdle:  time.Minute,
+			wantSource: map[string]Source{
+				"env": SourceEnv, "log-level": SourceFlag, "idle-timeout": SourceDefault,
+			},
+		},
+		{
+			name:      "nested file keys",
+			file:      "idle:\n  timeout: 2m\nlog_level: warn\n",
+			wantEnv:   "development",
+			wantLevel: "warn",
+			wantIdle:  2 * time.Minute,
+			wantSource: map[string]Source{
+				"env": SourceDefault, "log-level": SourceFile, "idle-timeout": SourceFile,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			var path string
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), "config.yaml")
+				err := os.WriteFile(path, []byte(tt.file), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+
+			env := fs.String("env", "development", "")
+			logLevel := fs.String("log-level", "info", "")
+			idleTimeout := fs.Duration("idle-timeout", time.Minute, "")
+
+			loader := Loader{FlagSet: fs, File: path, EnvPrefix: "TEST_"}
+			report, err := loader.Load(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *env != tt.wantEnv {
+				t.Errorf("got env %q; want %q", *env, tt.wantEnv)
+			}
+			if *logLevel != tt.wantLevel {
+				t.Errorf("got log level %q; want %q", *logLevel, tt.wantLevel)
+			}
+			if *idleTimeout != tt.wantIdle {
+				t.Errorf("got idle timeout %s; want %s", *idleTimeout, tt.wantIdle)
+			}
+			for name, want := range tt.wantSource {
+				if got := report[name]; got != want {
+					t.Errorf("got source %s for %s; want %s", got, name, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoaderReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"log-level": "warn", "cors": {"trusted-origins": ["https://a.example.com", "https://b.example.com"]}}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	var server Server
+	var cors Cors
+	server.RegisterFlags(fs, "", ":4000")
+	cors.RegisterFlags(fs, "")
+
+	loader := Loader{FlagSet: fs, File: path, EnvPrefix: "TEST_"}
+	_, err = loader.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if server.LogLevel != "warn" || len(cors.TrustedOrigins) != 2 {
+		t.Fatalf("got log level %q and origins %v after the first load", server.LogLevel, cors.TrustedOrigins)
+	}
+
+	// A value removed from the file goes back to its default.
+	err = os.WriteFile(path, []byte(`{"cors-trusted-origins": "https://c.example.com"}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = loader.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if server.LogLevel != "info" {
+		t.Errorf("got log level %q; want it reset to %q", server.LogLevel, "info")
+	}
+	if len(cors.TrustedOrigins) != 1 || cors.TrustedOrigins[0] != "https://c.example.com" {
+		t.Errorf("got origins %v; want [https://c.example.com]", cors.TrustedOrigins)
+	}
+}
+
+func TestLoaderReloadIntoNewFlagSet(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "smtp-password")
+	path := filepath.Join(dir, "config.yaml")
+
+	write := func(name, content string) {
+		t.Helper()
+		err := os.WriteFile(name, []byte(content), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	load := func() Smtp {
+		t.Helper()
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+
+		var cfg Smtp
+		cfg.RegisterFlags(fs, "", "localhost", "")
+
+		loader := Loader{FlagSet: fs, File: path, EnvPrefix: "TEST_"}
+		_, err := loader.Load(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	write(secret, "first\n")
+	write(path, "smtp-port: 2525\nsmtp-password: secretfile:"+secret+"\n")
+	running := load()
+
+	// A rotated secret and a changed file are both picked up by loading into
+	// a new FlagSet, without touching the values already loaded.
+	write(secret, "second\n")
+	write(path, "smtp-port: 587\nsmtp-password: secretfile:"+secret+"\n")
+	reloaded := load()
+
+	if reloaded.Port != 587 || reloaded.Password.Value() != "second" {
+		t.Errorf("got port %d and password %q after reloading; want 587 and %q",
+			reloaded.Port, reloaded.Password.Value(), "second")
+	}
+	if running.Port != 2525 || running.Password.Value() != "first" {
+		t.Errorf("got port %d and password %q for the running values; want 2525 and %q",
+			running.Port, running.Password.Value(), "first")
+	}
+}
+
+func TestLoaderUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	err := os.WriteFile(path, []byte("not-a-flag = 1\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("log-level", "info", "")
+
+	loader := Loader{FlagSet: fs, File: path}
+	_, err = loader.Load(nil)
+	if err == nil {
+		t.Fatal("got no error for an unknown configuration key")
+	}
+}
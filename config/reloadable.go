@@ -0,0 +1,50 @@
+package config
+
+import "sync"
+
+// Reloadable holds a value of type T, usually one of the config structs in
+// this package, that can be safely replaced whilst it is being read by other
+// goroutines. This allows settings to be changed at runtime without restarting
+// the service. Anything that needs to know when the value changes can
+// subscribe to be notified.
+type Reloadable[T any] struct {
+	mu   sync.RWMutex
+	val  T
+	subs []func(T)
+}
+
+// NewReloadable returns a pointer to a new Reloadable holding val.
+func NewReloadable[T any](val T) *Reloadable[T] {
+	return &Reloadable[T]{val: val}
+}
+
+// Get returns the current value.
+func (r *Reloadable[T]) Get() T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.val
+}
+
+// Set replaces the current value with val and then calls each of the
+// subscribers with the new value in the order they subscribed.
+func (r *Reloadable[T]) Set(val T) {
+	r.mu.Lock()
+	r.val = val
+	subs := make([]func(T), len(r.subs))
+	copy(subs, r.subs)
+	r.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(val)
+	}
+}
+
+// Subscribe registers fn to be called with the new value each time Set() is
+// called.
+func (r *Reloadable[T]) Subscribe(fn func(T)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subs = append(r.subs, fn)
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Redacted is what a Secret displays in place of its value.
+const Redacted = "[REDACTED]"
+
+const (
+	secretFilePrefix = "secretfile:"
+	secretEnvPrefix  = "secretenv:"
+)
+
+// Secret is a configuration value such as a password, key or DSN that should
+// never be displayed. It implements the flag.Value interface and can be set
+// from one of three kinds of source:
+//
+//   - secretfile:PATH reads the value from the file at PATH, such as a Docker
+//     or Kubernetes secret mount. A single trailing newline is removed.
+//   - secretenv:NAME reads the value from the environment variable NAME.
+//   - Anything else is taken literally as the value, so values such as a
+//     SQLite DSN of file:test.db are not mistaken for a file to read.
+//
+// Its String(), LogValue() and MarshalJSON() methods all return Redacted so
+// the value cannot be leaked through fmt, slog or encoding/json by accident;
+// the Value() method must be called explicitly to get at it. Copies of a
+// Secret share the same underlying value, so calling Set() or Reload() on one
+// of them, such as after a secret has been rotated, updates all of them. Both
+// are safe to call whilst other goroutines read the value, as long as the
+// Secret was first set before it was shared.
+type Secret struct {
+	state *secretState
+}
+
+type secretState struct {
+	mu     sync.RWMutex
+	source string
+	value  string
+}
+
+// ParseSecret returns a new Secret set from the given source. See the Secret
+// type for the supported formats.
+func ParseSecret(source string) (Secret, error) {
+	var s Secret
+	err := s.Set(source)
+	return s, err
+}
+
+// Set implements the flag.Value interface. If the source cannot be read, the
+// current value is kept and an error is returned.
+func (s *Secret) Set(source string) error {
+	value, err := readSecret(source)
+	if err != nil {
+		return err
+	}
+
+	if s.state == nil {
+		s.state = &secretState{source: source, value: value}
+		return nil
+	}
+
+	// Update the state in place under its lock, as it is shared with any
+	// copies of the Secret that may be reading it.
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+
+	s.state.source = source
+	s.state.value = value
+
+	return nil
+}
+
+// Reload reads the value of the Secret again from the file or environment
+// variable it was originally set from. It is a no-op for literal values. If the
+// source cannot be read, the current value is kept and an error is returned.
+func (s *Secret) Reload() error {
+	if s.state == nil {
+		return nil
+	}
+
+	s.state.mu.RLock()
+	source := s.state.source
+	s.state.mu.RUnlock()
+
+	value, err := readSecret(source)
+	if err != nil {
+		return err
+	}
+
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+
+	// Set() may have been called whilst the source was being read.
+	if s.state.source == source {
+		s.state.value = value
+	}
+
+	return nil
+}
+
+// Value returns the actual, unredacted value of the Secret.
+func (s Secret) Value() string {
+	if s.state == nil {
+		return ""
+	}
+
+	s.state.mu.RLock()
+	defer s.state.mu.RUnlock()
+
+	return s.state.value
+}
+
+// IsSet returns true if the Secret has a non-empty value.
+func (s Secret) IsSet() bool {
+	return s.Value() != ""
+}
+
+// String implements the fmt.Stringer and flag.Value interfaces. It returns
+// Redacted if the Secret has a value and an empty string otherwise.
+func (s Secret) String() string {
+	if !s.IsSet() {
+		return ""
+	}
+	return Redacted
+}
+
+// GoString implements the fmt.GoStringer interface so that the %#v verb does
+// not print the value.
+func (s Secret) GoString() string {
+	return fmt.Sprintf("config.Secret{%s}", Redacted)
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// MarshalJSON implements the encoding/json.Marshaler interface.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// readSecret returns the value referred to by source.
+func readSecret(source string) (string, error) {
+	switch {
+	case strings.HasPrefix(source, secretFilePrefix):
+		path := strings.TrimPrefix(source, secretFilePrefix)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("unable to read secret: %w", err)
+		}
+		value := strings.TrimSuffix(string(b), "\n")
+		return strings.TrimSuffix(value, "\r"), nil
+
+	case strings.HasPrefix(source, secretEnvPrefix):
+		name := strings.TrimPrefix(source, secretEnvPrefix)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("unable to read secret: environment variable %s is not set", name)
+		}
+		return value, nil
+
+	default:
+		return source, nil
+	}
+}
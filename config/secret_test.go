@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db-dsn")
+	err := os.WriteFile(path, []byte("postgres://user:pass@db/app\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SMTP_PASSWORD", "hunter2")
+
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantErr bool
+	}{
+		{"literal", "hunter2", "hunter2", false},
+		{"empty", "", "", false},
+		{"file", "secretfile:" + path, "postgres://user:pass@db/app", false},
+		{"missing file", "secretfile:" + path + ".missing", "", true},
+		{"environment variable", "secretenv:TEST_SMTP_PASSWORD", "hunter2", false},
+		{"missing environment variable", "secretenv:TEST_NOT_SET", "", true},
+		{"SQLite DSN", "file:test.db?cache=shared", "file:test.db?cache=shared", false},
+		{"env-like literal", "env:NAME", "env:NAME", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSecret(tt.source)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("got no error; want one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := s.Value(); got != tt.want {
+				t.Errorf("got value %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretRedacted(t *testing.T) {
+	s, err := ParseSecret("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, got := range []string{s.String(), fmt.Sprintf("%v", s), fmt.Sprintf("%#v", s)} {
+		if got == "hunter2" || !strings.Contains(got, Redacted) {
+			t.Errorf("got %q; want the value to be redacted", got)
+		}
+	}
+}
+
+func TestSecretReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	err := os.WriteFile(path, []byte("old"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := ParseSecret("secretfile:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := s
+
+	err = os.WriteFile(path, []byte("new"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if copied.Value() != "new" {
+		t.Errorf("got value %q in a copy after Reload; want %q", copied.Value(), "new")
+	}
+}
+
+func TestSecretSet(t *testing.T) {
+	s, err := ParseSecret("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := s
+
+	// Readers of the shared value do not race with Set.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					copied.Value()
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		err := s.Set(fmt.Sprintf("value-%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if copied.Value() != "value-99" {
+		t.Errorf("got value %q in a copy after Set; want %q", copied.Value(), "value-99")
+	}
+
+	// A source that cannot be read leaves the value alone.
+	err = s.Set("secretenv:TEST_NOT_SET")
+	if err == nil {
+		t.Fatal("got no error setting from a missing environment variable; want one")
+	}
+	if s.Value() != "value-99" {
+		t.Errorf("got value %q after a failed Set; want %q", s.Value(), "value-99")
+	}
+}
@@ -0,0 +1,342 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/validator"
+)
+
+// Environments that a Server can be configured to run in.
+const (
+	EnvDevelopment = "development"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+// Formats that a Server's logs can be written in.
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// Validatable is implemented by each of the config structs in this package so
+// that a whole configuration can be checked in one go with Validate().
+type Validatable interface {
+	Validate(v *validator.Validator)
+}
+
+// ValidationError holds every problem found with a configuration, keyed by the
+// name of the flag that sets the offending value. A problem with an item in a
+// list value is keyed by the flag name and the item's index, for example
+// cors-trusted-origins[2].
+type ValidationError struct {
+	Errors map[string]string
+}
+
+// Error implements the error interface. All of the problems are listed, one
+// per line and sorted by flag name, so that they can all be fixed at once.
+func (e *ValidationError) Error() string {
+	keys := make([]string, 0, len(e.Errors))
+	for key := range e.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("invalid configuration:")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "\n  %s: %s", key, e.Errors[key])
+	}
+
+	return b.String()
+}
+
+// Validate validates each of the given configs and returns a *ValidationError
+// containing all of the problems found, or nil if there were none.
+func Validate(cfgs ...Validatable) error {
+	v := validator.New()
+
+	for _, cfg := range cfgs {
+		cfg.Validate(v)
+	}
+
+	if !v.Valid() {
+		return &ValidationError{Errors: v.Errors}
+	}
+
+	return nil
+}
+
+// itemKey returns the key for the problems with the item at index i of the
+// list value set by the flag key, so that a problem with each item is kept.
+func itemKey(key string, i int) string {
+	return fmt.Sprintf("%s[%d]", key, i)
+}
+
+// validHostPort checks that addr is in the format [HOST]:PORT.
+func validHostPort(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	return err == nil && port != ""
+}
+
+// Validate checks the access log configuration and adds any problems to v.
+func (a *AccessLog) Validate(v *validator.Validator) {
+	v.Check(a.SampleRate >= 0 && a.SampleRate <= 1, flagName(a.prefix, "access-log-sample-rate"),
+		"must be between 0 and 1")
+
+	key := flagName(a.prefix, "access-log-exclude")
+	for i, path := range a.ExcludePaths {
+		v.Check(strings.HasPrefix(path, "/"), itemKey(key, i),
+			fmt.Sprintf("path must start with /: %s", path))
+	}
+
+	v.Check(validator.PermittedValue(a.Format, AccessLogStructured, AccessLogCombined),
+		flagName(a.prefix, "access-log-format"), "must be one of structured or combined")
+}
+
+// Validate checks the auth service configuration and adds any problems to v.
+func (a *AuthService) Validate(v *validator.Validator) {
+	if a.URL != "" {
+		u, err := url.Parse(a.URL)
+		v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			flagName(a.prefix, "auth-url"), "must be an absolute http or https URL")
+	} else {
+		key := flagName(a.prefix, "auth-addr")
+		v.Check(a.Addr != "", key, "must be provided")
+		if a.Addr != "" {
+			v.Check(validHostPort(a.Addr), key, "must be in the format [HOST]:PORT")
+		}
+	}
+
+	v.Check(a.Timeout > 0, flagName(a.prefix, "auth-timeout"), "must be greater than zero")
+	v.Check(a.CacheTTL >= 0, flagName(a.prefix, "auth-cache-ttl"), "must not be negative")
+	v.Check(a.BreakerThreshold > 0, flagName(a.prefix, "auth-breaker-threshold"), "must be greater than zero")
+	v.Check(a.BreakerCooldown > 0, flagName(a.prefix, "auth-breaker-cooldown"), "must be greater than zero")
+}
+
+// Validate checks the CORS configuration and adds any problems to v.
+func (c *Cors) Validate(v *validator.Validator) {
+	methods := []string{
+		http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodPatch, http.MethodPost, http.MethodPut,
+	}
+	key := flagName(c.prefix, "cors-allow-methods")
+	for i, method := range c.AllowMethods {
+		v.Check(validator.PermittedValue(method, methods...), itemKey(key, i),
+			fmt.Sprintf("invalid HTTP method for CORS: %s", method))
+	}
+
+	key = flagName(c.prefix, "cors-trusted-origins")
+	for i, origin := range c.TrustedOrigins {
+		if origin == "*" {
+			v.Check(!c.AllowCredentials, itemKey(key, i),
+				"must not contain * when cors-allow-credentials is set")
+			continue
+		}
+
+		v.Check(strings.Count(origin, "*") <= 1, itemKey(key, i),
+			fmt.Sprintf("invalid origin pattern: %s", origin))
+		// Substitute the wildcard so that the pattern can be validated as a URL.
+		validator.ValidateURLHTTP(v, strings.Replace(origin, "*", "x", 1), itemKey(key, i))
+	}
+
+	v.Check(c.MaxAge >= 0, flagName(c.prefix, "cors-max-age"), "must not be negative")
+}
+
+// Validate checks the JWT configuration and adds any problems to v. There must
+// be a key source for each of the accepted algorithms.
+func (j *JWT) Validate(v *validator.Validator) {
+	key := flagName(j.prefix, "jwt-algorithms")
+	v.Check(len(j.Algorithms) > 0, key, "must be provided")
+
+	for i, alg := range j.Algorithms {
+		switch alg {
+		case JWTAlgorithmHS256:
+			v.Check(len(j.HMACSecret.Value()) >= 32, flagName(j.prefix, "jwt-hmac-secret"),
+				"must be at least 32 characters long when HS256 is accepted")
+		case JWTAlgorithmRS256, JWTAlgorithmEdDSA:
+			v.Check(j.JWKSFile != "" || j.JWKSURL != "", flagName(j.prefix, "jwt-jwks-url"),
+				"jwt-jwks-file or jwt-jwks-url must be provided when RS256 or EdDSA are accepted")
+		default:
+			v.AddError(itemKey(key, i), fmt.Sprintf("unsupported algorithm: %s", alg))
+		}
+	}
+
+	v.Check(j.JWKSFile == "" || j.JWKSURL == "", flagName(j.prefix, "jwt-jwks-url"),
+		"must not be provided along with jwt-jwks-file")
+	if j.JWKSURL != "" {
+		validator.ValidateURLHTTP(v, j.JWKSURL, flagName(j.prefix, "jwt-jwks-url"))
+	}
+	v.Check(j.JWKSRefresh > 0, flagName(j.prefix, "jwt-jwks-refresh"), "must be greater than zero")
+	v.Check(j.Leeway >= 0, flagName(j.prefix, "jwt-leeway"), "must not be negative")
+}
+
+// Validate checks the rate limiter configuration and adds any problems to v.
+// The limits are only checked if the rate limiter is active.
+func (l *Limiter) Validate(v *validator.Validator) {
+	if !l.Active {
+		return
+	}
+
+	v.Check(l.RPS > 0, flagName(l.prefix, "limiter-rps"), "must be greater than zero")
+	v.Check(l.Burst > 0, flagName(l.prefix, "limiter-burst"), "must be greater than zero")
+
+	key := flagName(l.prefix, "limiter-policies")
+	for i, p := range l.Policies {
+		v.Check(p.RPS > 0 && p.Burst > 0, itemKey(key, i),
+			fmt.Sprintf("RPS and burst must be greater than zero in policy %s", p))
+	}
+}
+
+// Validate checks the MongoDB configuration and adds any problems to v.
+func (m *MongoDB) Validate(v *validator.Validator) {
+	v.Check(m.Host != "", flagName(m.prefix, "mongo-host"), "must be provided")
+	v.Check(m.Schema != "", flagName(m.prefix, "mongo-schema"), "must be provided")
+}
+
+// Validate checks the trusted proxy configuration and adds any problems to v.
+func (p *Proxy) Validate(v *validator.Validator) {
+	key := flagName(p.prefix, "proxy-trusted")
+	for i, proxy := range p.TrustedProxies {
+		_, errPrefix := netip.ParsePrefix(proxy)
+		_, errAddr := netip.ParseAddr(proxy)
+		v.Check(errPrefix == nil || errAddr == nil, itemKey(key, i),
+			fmt.Sprintf("invalid CIDR range or IP address: %s", proxy))
+	}
+
+	v.Check(validator.PermittedValue(strings.ToLower(p.Header), "", "forwarded", "x-forwarded-for", "x-real-ip"),
+		flagName(p.prefix, "proxy-header"), "must be one of Forwarded, X-Forwarded-For or X-Real-IP")
+}
+
+// Validate checks the web application server configuration and adds any
+// problems to v.
+func (s *Server) Validate(v *validator.Validator) {
+	v.Check(validHostPort(s.Addr), flagName(s.prefix, "addr"),
+		"must be in the format [HOST]:PORT")
+	v.Check(validator.PermittedValue(s.Env, EnvDevelopment, EnvStaging, EnvProduction),
+		flagName(s.prefix, "env"), "must be one of development, staging or production")
+
+	var level slog.Level
+	err := level.UnmarshalText([]byte(s.LogLevel))
+	v.Check(err == nil, flagName(s.prefix, "log-level"),
+		"must be one of debug, info, warn or error")
+	v.Check(validator.PermittedValue(s.LogFormat, LogFormatJSON, LogFormatText),
+		flagName(s.prefix, "log-format"), "must be one of json or text")
+
+	err = level.UnmarshalText([]byte(s.ErrorLogLevel))
+	v.Check(err == nil, flagName(s.prefix, "error-log-level"),
+		"must be one of debug, info, warn or error")
+
+	if s.AdminToken.IsSet() {
+		v.Check(len(s.AdminToken.Value()) >= 16, flagName(s.prefix, "admin-token"),
+			"must be at least 16 characters long")
+	}
+
+	timeouts := map[string]time.Duration{
+		"idle-timeout":        s.IdleTimeout,
+		"read-timeout":        s.ReadTimeout,
+		"read-header-timeout": s.ReadHeaderTimeout,
+		"write-timeout":       s.WriteTimeout,
+		"shutdown-timeout":    s.ShutdownTimeout,
+	}
+	for name, timeout := range timeouts {
+		v.Check(timeout >= 0, flagName(s.prefix, name), "must not be negative")
+	}
+	v.Check(s.MaxHeaderBytes >= 0, flagName(s.prefix, "max-header-bytes"),
+		"must not be negative")
+
+	certKey := flagName(s.prefix, "tls-cert-file")
+	keyKey := flagName(s.prefix, "tls-key-file")
+	caKey := flagName(s.prefix, "tls-client-ca-file")
+	v.Check(s.TLSKeyFile == "" || s.TLSCertFile != "", certKey,
+		"must be provided when tls-key-file is")
+	v.Check(s.TLSCertFile == "" || s.TLSKeyFile != "", keyKey,
+		"must be provided when tls-cert-file is")
+	v.Check(s.TLSClientCAFile == "" || s.TLSEnabled(), caKey,
+		"requires tls-cert-file and tls-key-file to be provided")
+
+	files := map[string]string{
+		certKey: s.TLSCertFile,
+		keyKey:  s.TLSKeyFile,
+		caKey:   s.TLSClientCAFile,
+	}
+	for key, path := range files {
+		if path != "" {
+			_, err := os.Stat(path)
+			v.Check(err == nil, key, "must be a readable file")
+		}
+	}
+}
+
+// Validate checks the external service configuration and adds any problems to
+// v.
+func (s *Service) Validate(v *validator.Validator) {
+	key := s.name
+	if key == "" {
+		key = "service-addr"
+	}
+
+	v.Check(s.Addr != "", key, "must be provided")
+	if s.Addr != "" {
+		validator.ValidateURLHTTP(v, s.Addr, key)
+	}
+}
+
+// Validate checks the SMTP server connection configuration and adds any
+// problems to v.
+func (s *Smtp) Validate(v *validator.Validator) {
+	v.Check(s.Host != "", flagName(s.prefix, "smtp-host"), "must be provided")
+	v.Check(s.Port > 0 && s.Port <= 65535, flagName(s.prefix, "smtp-port"),
+		"must be between 1 and 65535")
+
+	_, err := mail.ParseAddress(s.Sender)
+	v.Check(err == nil, flagName(s.prefix, "smtp-sender"),
+		"must be a valid address, format: Name <email@address.com>")
+}
+
+// Validate checks the SQL database configuration and adds any problems to v.
+// As well as checking the values themselves, it checks that the driver has
+// been registered with the database/sql package.
+func (s *SqlDB) Validate(v *validator.Validator) {
+	key := flagName(s.prefix, "db-driver-name")
+	v.Check(s.Driver != "", key, "must be provided")
+	if s.Driver != "" {
+		v.Check(validator.PermittedValue(s.Driver, sql.Drivers()...), key,
+			fmt.Sprintf("driver %q is not registered, check it has been imported", s.Driver))
+	}
+
+	v.Check(s.DSN.IsSet(), flagName(s.prefix, "db-dsn"), "must be provided")
+	v.Check(s.MaxOpenConns >= 0, flagName(s.prefix, "db-max-open-conns"),
+		"must not be negative")
+	v.Check(s.MaxIdleConns >= 0, flagName(s.prefix, "db-max-idle-conns"),
+		"must not be negative")
+
+	_, err := time.ParseDuration(s.MaxIdleTime)
+	v.Check(err == nil, flagName(s.prefix, "db-max-idle-time"),
+		"must be a valid duration, e.g. 15m")
+}
+
+// Validate checks the tracing configuration and adds any problems to v. The
+// OTLP settings are only checked if the OTLP exporter is used.
+func (t *Tracing) Validate(v *validator.Validator) {
+	v.Check(validator.PermittedValue(t.Exporter, TracingExporterNone, TracingExporterStdout, TracingExporterOTLP),
+		flagName(t.prefix, "tracing-exporter"), "must be one of none, stdout or otlp")
+	v.Check(t.SampleRatio >= 0 && t.SampleRatio <= 1, flagName(t.prefix, "tracing-sample-ratio"),
+		"must be between 0 and 1")
+
+	if t.Exporter == TracingExporterOTLP {
+		v.Check(validHostPort(t.OTLPEndpoint), flagName(t.prefix, "tracing-otlp-endpoint"),
+			"must be in the format HOST:PORT")
+	}
+}
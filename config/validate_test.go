@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfgs       []Validatable
+		wantErrors map[string]string
+	}{
+		{
+			name: "valid",
+			cfgs: []Validatable{
+				&Cors{AllowMethods: []string{"GET", "POST"}, TrustedOrigins: []string{"https://*.example.com"}},
+				&Proxy{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}, Header: "X-Forwarded-For"},
+			},
+		},
+		{
+			name: "every bad list item",
+			cfgs: []Validatable{
+				&AccessLog{ExcludePaths: []string{"health", "/debug", "metrics"}, Format: AccessLogStructured},
+				&Cors{
+					AllowMethods:     []string{"GET", "FETCH", "PURGE"},
+					TrustedOrigins:   []string{"*", "https://www.example.com", "ftp://example.com"},
+					AllowCredentials: true,
+				},
+				&Limiter{Active: true, RPS: 2, Burst: 4, Policies: []LimiterPolicy{
+					{Path: "/v1/a", RPS: 0, Burst: 1},
+					{Path: "/v1/b", RPS: 1, Burst: 1},
+					{Path: "/v1/c", RPS: 1, Burst: 0},
+				}},
+				&Proxy{TrustedProxies: []string{"10.0.0.0/33", "192.168.1.1", "proxy"}},
+			},
+			wantErrors: map[string]string{
+				"access-log-exclude[0]":   "path must start with /: health",
+				"access-log-exclude[2]":   "path must start with /: metrics",
+				"cors-allow-methods[1]":   "invalid HTTP method for CORS: FETCH",
+				"cors-allow-methods[2]":   "invalid HTTP method for CORS: PURGE",
+				"cors-trusted-origins[0]": "must not contain * when cors-allow-credentials is set",
+				"cors-trusted-origins[2]": "Must begin with http:// or https://",
+				"limiter-policies[0]":     "RPS and burst must be greater than zero in policy /v1/a=0,1",
+				"limiter-policies[2]":     "RPS and burst must be greater than zero in policy /v1/c=1,0",
+				"proxy-trusted[0]":        "invalid CIDR range or IP address: 10.0.0.0/33",
+				"proxy-trusted[2]":        "invalid CIDR range or IP address: proxy",
+			},
+		},
+		{
+			name: "problems across configs",
+			cfgs: []Validatable{
+				&Server{Addr: "localhost", Env: "testing", LogLevel: "info", LogFormat: LogFormatJSON, ErrorLogLevel: "warn"},
+				&Smtp{Host: "", Port: 70000, Sender: "Movies <noreply@example.com>"},
+				&Tracing{Exporter: TracingExporterNone, SampleRatio: 1, OTLPEndpoint: "collector"},
+			},
+			wantErrors: map[string]string{
+				"addr":      "must be in the format [HOST]:PORT",
+				"env":       "must be one of development, staging or production",
+				"smtp-host": "must be provided",
+				"smtp-port": "must be between 1 and 65535",
+			},
+		},
+		{
+			name: "prefixed flag names",
+			cfgs: []Validatable{
+				&Proxy{TrustedProxies: []string{"proxy"}, prefix: "internal"},
+				&Smtp{Port: 25, Sender: "Movies <noreply@example.com>", prefix: "alerts"},
+			},
+			wantErrors: map[string]string{
+				"internal-proxy-trusted[0]": "invalid CIDR range or IP address: proxy",
+				"alerts-smtp-host":          "must be provided",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.cfgs...)
+			if len(tt.wantErrors) == 0 {
+				if err != nil {
+					t.Fatalf("got error %v; want none", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got error %v; want a *ValidationError", err)
+			}
+			if len(verr.Errors) != len(tt.wantErrors) {
+				t.Errorf("got %d problems; want %d: %v", len(verr.Errors), len(tt.wantErrors), verr.Errors)
+			}
+			for key, want := range tt.wantErrors {
+				if got := verr.Errors[key]; got != want {
+					t.Errorf("got problem %q for %s; want %q", got, key, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationErrorString(t *testing.T) {
+	err := &ValidationError{Errors: map[string]string{
+		"smtp-port": "must be between 1 and 65535",
+		"addr":      "must be in the format [HOST]:PORT",
+	}}
+
+	want := "invalid configuration:\n" +
+		"  addr: must be in the format [HOST]:PORT\n" +
+		"  smtp-port: must be between 1 and 65535"
+	if got := err.Error(); got != want {
+		t.Errorf("got error %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+// Package cache provides a bounded in-memory cache of values that expire after
+// a fixed time to live, shared by the packages that cache lookups from
+// authentication and permission stores.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity is the number of entries a Cache holds when it is not given
+// a capacity of its own.
+const DefaultCapacity = 10_000
+
+// Cache is a map of values that expire after a fixed time to live, holding at
+// most a fixed number of entries. When it is full, the least recently used
+// entry is evicted to make room for a new one, so callers cannot grow it
+// without limit by looking up arbitrary keys. A ttl of zero disables it. It is
+// safe for concurrent use.
+type Cache[V any] struct {
+	ttl      time.Duration
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type entry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// New returns a pointer to a new Cache that keeps values for ttl and holds at
+// most capacity entries. A capacity of zero or less means DefaultCapacity.
+func New[V any](ttl time.Duration, capacity int) *Cache[V] {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+
+	return &Cache[V]{
+		ttl:      ttl,
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the value for key if there is one that has not expired.
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+
+	elem, found := c.entries[key]
+	if !found {
+		return zero, false
+	}
+
+	e := elem.Value.(*entry[V])
+	if time.Now().After(e.expires) {
+		c.remove(elem)
+		return zero, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return e.value, true
+}
+
+// Set stores value for key until the ttl has passed, evicting the least
+// recently used entry if the cache is full.
+func (c *Cache[V]) Set(key string, value V) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+
+	elem, found := c.entries[key]
+	if found {
+		e := elem.Value.(*entry[V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	if c.order.Len() >= c.capacity {
+		c.remove(c.order.Back())
+	}
+
+	c.entries[key] = c.order.PushFront(&entry[V]{key: key, value: value, expires: expires})
+}
+
+// Delete removes the value for key.
+func (c *Cache[V]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, found := c.entries[key]
+	if found {
+		c.remove(elem)
+	}
+}
+
+// Len returns the number of entries in the cache, including any that have
+// expired but not yet been removed.
+func (c *Cache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// remove deletes elem from the cache. It must be called with c.mu held.
+func (c *Cache[V]) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry[V]).key)
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCacheCapacity(t *testing.T) {
+	c := New[int](time.Minute, 3)
+
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), i)
+	}
+
+	if got := c.Len(); got != 3 {
+		t.Fatalf("got %d entries; want 3", got)
+	}
+	for i := 0; i < 7; i++ {
+		if _, found := c.Get(strconv.Itoa(i)); found {
+			t.Errorf("got an entry for %d; want it to have been evicted", i)
+		}
+	}
+	for i := 7; i < 10; i++ {
+		if v, found := c.Get(strconv.Itoa(i)); !found || v != i {
+			t.Errorf("got %d and %t for %d; want %d and true", v, found, i, i)
+		}
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[string](time.Minute, 2)
+
+	c.Set("a", "a")
+	c.Set("b", "b")
+	c.Get("a")
+	c.Set("c", "c")
+
+	if _, found := c.Get("b"); found {
+		t.Error("got an entry for b; want it to have been evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, found := c.Get(key); !found {
+			t.Errorf("got no entry for %s; want one", key)
+		}
+	}
+
+	// Updating an entry does not take up any more room.
+	c.Set("a", "A")
+	if v, _ := c.Get("a"); v != "A" || c.Len() != 2 {
+		t.Errorf("got %q and %d entries; want %q and 2", v, c.Len(), "A")
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := New[int](20*time.Millisecond, 0)
+
+	c.Set("a", 1)
+	if _, found := c.Get("a"); !found {
+		t.Fatal("got no entry; want one before the ttl has passed")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, found := c.Get("a"); found {
+		t.Error("got an entry; want none after the ttl has passed")
+	}
+	if got := c.Len(); got != 0 {
+		t.Errorf("got %d entries; want the expired entry to be removed", got)
+	}
+}
+
+func TestCacheDisabledAndDelete(t *testing.T) {
+	disabled := New[int](0, 0)
+	disabled.Set("a", 1)
+	if _, found := disabled.Get("a"); found {
+		t.Error("got an entry from a cache with a ttl of zero; want none")
+	}
+
+	c := New[int](time.Minute, 0)
+	c.Set("a", 1)
+	c.Delete("a")
+	c.Delete("missing")
+	if _, found := c.Get("a"); found || c.Len() != 0 {
+		t.Error("got an entry after Delete; want none")
+	}
+}
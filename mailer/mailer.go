@@ -2,32 +2,66 @@ package mailer
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"html/template"
 	"time"
 
 	"github.com/go-mail/mail/v2"
 	"github.com/m5lapp/go-service-toolkit/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracerName is the name of the OpenTelemetry tracer used by this package.
+const tracerName = "github.com/m5lapp/go-service-toolkit/mailer"
+
+// Mailer sends emails rendered from templates in templateFS via an SMTP
+// server.
 type Mailer struct {
-	dialer     *mail.Dialer
-	sender     string
+	cfg        *config.Reloadable[config.Smtp]
 	templateFS embed.FS
 }
 
+// New returns a new Mailer that sends emails using the SMTP server in cfg.
 func New(cfg *config.Smtp, templateFS embed.FS) Mailer {
-	dialer := mail.NewDialer(cfg.Host, cfg.Port, cfg.Username, cfg.Password)
-	dialer.Timeout = 5 * time.Second
+	return NewReloadable(config.NewReloadable(*cfg), templateFS)
+}
 
+// NewReloadable returns a new Mailer that reads the SMTP server configuration
+// from cfg each time an email is sent, so that the server and its credentials
+// can be changed whilst the application is running.
+func NewReloadable(cfg *config.Reloadable[config.Smtp], templateFS embed.FS) Mailer {
 	return Mailer{
-		dialer:     dialer,
-		sender:     cfg.Sender,
+		cfg:        cfg,
 		templateFS: templateFS,
 	}
 }
 
+// Send renders the subject, plainBody and htmlBody templates in templateFile
+// with data and emails the result to recipient, retrying up to three times.
 func (m Mailer) Send(recipient, templateFile string, data any) error {
+	return m.SendContext(context.Background(), recipient, templateFile, data)
+}
+
+// SendContext is the same as Send, except that the email is recorded as an
+// OpenTelemetry span that is a child of any span in ctx. The recipient is not
+// recorded in the span.
+func (m Mailer) SendContext(ctx context.Context, recipient, templateFile string, data any) (err error) {
+	_, span := otel.Tracer(tracerName).Start(ctx, "mailer.Send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("mailer.template", templateFile)),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	tmpl, err := template.New("email").ParseFS(m.templateFS, "templates/"+templateFile)
 	if err != nil {
 		return err
@@ -51,15 +85,23 @@ func (m Mailer) Send(recipient, templateFile string, data any) error {
 		return err
 	}
 
+	cfg := m.cfg.Get()
+	dialer := mail.NewDialer(cfg.Host, cfg.Port, cfg.Username, cfg.Password.Value())
+	dialer.Timeout = 5 * time.Second
+
 	msg := mail.NewMessage()
 	msg.SetHeader("To", recipient)
-	msg.SetHeader("From", m.sender)
+	msg.SetHeader("From", cfg.Sender)
 	msg.SetHeader("Subject", subject.String())
 	msg.SetBody("text/plain", plainBody.String())
 	msg.AddAlternative("text/html", htmlBody.String())
 
+	span.SetAttributes(attribute.String("server.address", cfg.Host), attribute.Int("server.port", cfg.Port))
+
 	for i := 0; i < 3; i++ {
-		err = m.dialer.DialAndSend(msg)
+		span.SetAttributes(attribute.Int("mailer.attempts", i+1))
+
+		err = dialer.DialAndSend(msg)
 		if nil == err {
 			return nil
 		}
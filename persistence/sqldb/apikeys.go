@@ -0,0 +1,215 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+)
+
+// APIKeyStore is a webapp.APIKeyStore that keeps API keys in a SQL database.
+// Only the SHA-256 hash of each key is stored, and its scopes are stored as a
+// space-separated list. The queries use PostgreSQL syntax and expect a table
+// such as the following to exist:
+//
+//	CREATE TABLE IF NOT EXISTS api_keys (
+//	    id           bigserial PRIMARY KEY,
+//	    principal_id text NOT NULL,
+//	    name         text NOT NULL DEFAULT '',
+//	    prefix       text NOT NULL,
+//	    hash         bytea NOT NULL UNIQUE,
+//	    scopes       text NOT NULL DEFAULT '',
+//	    expires_at   timestamp with time zone,
+//	    last_used_at timestamp with time zone,
+//	    created_at   timestamp with time zone NOT NULL DEFAULT now()
+//	);
+//
+// The DB can be a *sql.DB or a *TracedDB if the queries should be traced.
+//
+// ActivatedQuery is used by GetByHash() to check whether the account of the
+// principal a key was issued to is activated. It is given the principal ID as
+// $1 and must return a single boolean, for example:
+//
+//	SELECT activated FROM users WHERE id::text = $1
+//
+// A principal that it returns no row for is treated as not activated. If
+// ActivatedQuery is empty, then every principal is treated as activated, as
+// the api_keys table says nothing about the accounts it refers to.
+type APIKeyStore struct {
+	DB             Querier
+	ActivatedQuery string
+}
+
+// NewAPIKeyStore returns a pointer to a new APIKeyStore that uses db.
+func NewAPIKeyStore(db Querier) *APIKeyStore {
+	return &APIKeyStore{DB: db}
+}
+
+// Insert stores key, such as one created with access.NewAPIKey(), and sets its
+// KeyID and CreatedAt fields.
+func (s *APIKeyStore) Insert(ctx context.Context, key *access.APIKey) error {
+	query := `
+		INSERT INTO api_keys (principal_id, name, prefix, hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	args := []any{
+		key.PrincipalID,
+		key.Name,
+		key.Prefix,
+		key.Hash,
+		strings.Join(key.Scopes, " "),
+		nullTime(key.ExpiresAt),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return s.DB.QueryRowContext(ctx, query, args...).Scan(&key.KeyID, &key.CreatedAt)
+}
+
+// GetByHash implements the webapp.APIKeyStore interface. Expired keys are
+// still returned so that the caller can tell them apart. The key's
+// PrincipalActivated field is set using the ActivatedQuery.
+func (s *APIKeyStore) GetByHash(ctx context.Context, hash []byte) (*access.APIKey, error) {
+	query := `
+		SELECT id, principal_id, name, prefix, hash, scopes, expires_at, last_used_at, created_at
+		FROM api_keys
+		WHERE hash = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	key, err := scanAPIKey(s.DB.QueryRowContext(ctx, query, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, access.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key.PrincipalActivated = true
+	if s.ActivatedQuery != "" {
+		err = s.DB.QueryRowContext(ctx, s.ActivatedQuery, key.PrincipalID).Scan(&key.PrincipalActivated)
+		if errors.Is(err, sql.ErrNoRows) {
+			key.PrincipalActivated = false
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// GetAllForPrincipal returns all of the API keys issued to the principal with
+// the given ID, most recently created first.
+func (s *APIKeyStore) GetAllForPrincipal(ctx context.Context, principalID string) ([]*access.APIKey, error) {
+	query := `
+		SELECT id, principal_id, name, prefix, hash, scopes, expires_at, last_used_at, created_at
+		FROM api_keys
+		WHERE principal_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, query, principalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*access.APIKey{}
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Touch implements the webapp.APIKeyStore interface.
+func (s *APIKeyStore) Touch(ctx context.Context, id int64, lastUsedAt time.Time) error {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, query, id, lastUsedAt)
+	return err
+}
+
+// Delete revokes the API key with the given ID that was issued to the principal
+// with the given ID. If there is no such key, then access.ErrAPIKeyNotFound is
+// returned.
+func (s *APIKeyStore) Delete(ctx context.Context, principalID string, id int64) error {
+	query := `
+		DELETE FROM api_keys
+		WHERE id = $1 AND principal_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, query, id, principalID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return access.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// scanAPIKey scans a row of the api_keys table into a new access.APIKey.
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (*access.APIKey, error) {
+	var key access.APIKey
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullTime
+
+	err := row.Scan(
+		&key.KeyID,
+		&key.PrincipalID,
+		&key.Name,
+		&key.Prefix,
+		&key.Hash,
+		&scopes,
+		&expiresAt,
+		&lastUsedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = strings.Fields(scopes)
+	key.ExpiresAt = expiresAt.Time
+	key.LastUsedAt = lastUsedAt.Time
+
+	return &key, nil
+}
+
+// nullTime returns t as a sql.NullTime that is NULL if t is the zero time.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
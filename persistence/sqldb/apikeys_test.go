@@ -0,0 +1,188 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+)
+
+var apiKeyColumns = []string{
+	"id", "principal_id", "name", "prefix", "hash", "scopes", "expires_at", "last_used_at", "created_at",
+}
+
+func TestAPIKeyStoreGetByHash(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expires := created.Add(24 * time.Hour)
+
+	tests := []struct {
+		name        string
+		rows        [][]driver.Value
+		wantErr     error
+		wantScopes  []string
+		wantExpires time.Time
+	}{
+		{
+			name: "key with scopes and expiry",
+			rows: [][]driver.Value{
+				{int64(1), "user-1", "CI", "test_abcd", []byte("hash"), "movies:read movies:write", expires, nil, created},
+			},
+			wantScopes:  []string{"movies:read", "movies:write"},
+			wantExpires: expires,
+		},
+		{
+			name: "key without scopes or expiry",
+			rows: [][]driver.Value{
+				{int64(2), "user-1", "", "test_abcd", []byte("hash"), "", nil, nil, created},
+			},
+			wantScopes: nil,
+		},
+		{
+			name:    "unknown key",
+			wantErr: access.ErrAPIKeyNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, fakeResult{columns: apiKeyColumns, rows: tt.rows})
+			store := NewAPIKeyStore(db)
+
+			key, err := store.GetByHash(context.Background(), []byte("hash"))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if key.PrincipalID != "user-1" || !key.CreatedAt.Equal(created) {
+				t.Errorf("got key %+v", key)
+			}
+			if !slices.Equal(key.Scopes, tt.wantScopes) {
+				t.Errorf("got scopes %q; want %q", key.Scopes, tt.wantScopes)
+			}
+			if !key.ExpiresAt.Equal(tt.wantExpires) {
+				t.Errorf("got expiry %s; want %s", key.ExpiresAt, tt.wantExpires)
+			}
+			if !key.LastUsedAt.IsZero() {
+				t.Errorf("got last used time %s; want the zero time", key.LastUsedAt)
+			}
+		})
+	}
+}
+
+func TestAPIKeyStoreGetByHashActivated(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	keyRow := fakeResult{columns: apiKeyColumns, rows: [][]driver.Value{
+		{int64(1), "user-1", "CI", "test_abcd", []byte("hash"), "movies:read", nil, nil, created},
+	}}
+
+	tests := []struct {
+		name           string
+		activatedQuery string
+		results        []fakeResult
+		want           bool
+	}{
+		{"no activated query", "", []fakeResult{keyRow}, true},
+		{
+			name:           "activated principal",
+			activatedQuery: "SELECT activated FROM users WHERE id::text = $1",
+			results:        []fakeResult{keyRow, {columns: []string{"activated"}, rows: [][]driver.Value{{true}}}},
+			want:           true,
+		},
+		{
+			name:           "deactivated principal",
+			activatedQuery: "SELECT activated FROM users WHERE id::text = $1",
+			results:        []fakeResult{keyRow, {columns: []string{"activated"}, rows: [][]driver.Value{{false}}}},
+			want:           false,
+		},
+		{
+			name:           "unknown principal",
+			activatedQuery: "SELECT activated FROM users WHERE id::text = $1",
+			results:        []fakeResult{keyRow, {columns: []string{"activated"}}},
+			want:           false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, tt.results...)
+			store := NewAPIKeyStore(db)
+			store.ActivatedQuery = tt.activatedQuery
+
+			key, err := store.GetByHash(context.Background(), []byte("hash"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if key.IsActivated() != tt.want {
+				t.Errorf("got activated %t; want %t", key.IsActivated(), tt.want)
+			}
+			if len(fake.calls) != len(tt.results) {
+				t.Fatalf("got %d queries; want %d", len(fake.calls), len(tt.results))
+			}
+			if tt.activatedQuery != "" && fake.calls[1].args[0] != "user-1" {
+				t.Errorf("got principal ID argument %v; want %q", fake.calls[1].args[0], "user-1")
+			}
+		})
+	}
+}
+
+func TestAPIKeyStoreInsert(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	db, fake := newFakeDB(t, fakeResult{
+		columns: []string{"id", "created_at"},
+		rows:    [][]driver.Value{{int64(7), created}},
+	})
+	store := NewAPIKeyStore(db)
+
+	_, key, err := access.NewAPIKey("test", "user-1", "CI", []string{"movies:read", "movies:write"}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Insert(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key.KeyID != 7 || !key.CreatedAt.Equal(created) {
+		t.Errorf("got ID %d and created time %s", key.KeyID, key.CreatedAt)
+	}
+
+	args := fake.calls[0].args
+	if args[4] != "movies:read movies:write" {
+		t.Errorf("got scopes argument %v; want them space separated", args[4])
+	}
+	if args[5] != nil {
+		t.Errorf("got expiry argument %v; want NULL for no expiry", args[5])
+	}
+}
+
+func TestAPIKeyStoreDelete(t *testing.T) {
+	tests := []struct {
+		name         string
+		rowsAffected int64
+		wantErr      error
+	}{
+		{"deleted", 1, nil},
+		{"not found", 0, access.ErrAPIKeyNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, fakeResult{rowsAffected: tt.rowsAffected})
+			store := NewAPIKeyStore(db)
+
+			err := store.Delete(context.Background(), "user-1", 7)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v; want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+)
+
+// PermissionStore is a webapp.PermissionStore that keeps permissions, roles and
+// the grants of them to principals in a SQL database. A principal has the
+// permissions granted to them directly as well as those of each of their roles.
+// The queries use PostgreSQL syntax and expect tables such as the following to
+// exist:
+//
+//	CREATE TABLE IF NOT EXISTS permissions (
+//	    id   bigserial PRIMARY KEY,
+//	    code text NOT NULL UNIQUE
+//	);
+//
+//	CREATE TABLE IF NOT EXISTS roles (
+//	    id   bigserial PRIMARY KEY,
+//	    name text NOT NULL UNIQUE
+//	);
+//
+//	CREATE TABLE IF NOT EXISTS roles_permissions (
+//	    role_id       bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+//	    permission_id bigint NOT NULL REFERENCES permissions ON DELETE CASCADE,
+//	    PRIMARY KEY (role_id, permission_id)
+//	);
+//
+//	CREATE TABLE IF NOT EXISTS principals_roles (
+//	    principal_id text NOT NULL,
+//	    role_id      bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+//	    PRIMARY KEY (principal_id, role_id)
+//	);
+//
+//	CREATE TABLE IF NOT EXISTS principals_permissions (
+//	    principal_id  text NOT NULL,
+//	    permission_id bigint NOT NULL REFERENCES permissions ON DELETE CASCADE,
+//	    PRIMARY KEY (principal_id, permission_id)
+//	);
+//
+// The DB can be a *sql.DB or a *TracedDB if the queries should be traced.
+type PermissionStore struct {
+	DB Querier
+}
+
+// NewPermissionStore returns a pointer to a new PermissionStore that uses db.
+func NewPermissionStore(db Querier) *PermissionStore {
+	return &PermissionStore{DB: db}
+}
+
+// PermissionsForPrincipal implements the webapp.PermissionStore interface.
+func (s *PermissionStore) PermissionsForPrincipal(ctx context.Context, principalID string) (access.Permissions, error) {
+	query := `
+		SELECT p.code
+		FROM permissions p
+		INNER JOIN principals_permissions pp ON pp.permission_id = p.id
+		WHERE pp.principal_id = $1
+		UNION
+		SELECT p.code
+		FROM permissions p
+		INNER JOIN roles_permissions rp ON rp.permission_id = p.id
+		INNER JOIN principals_roles pr ON pr.role_id = rp.role_id
+		WHERE pr.principal_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, query, principalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions access.Permissions
+
+	for rows.Next() {
+		var code string
+
+		err := rows.Scan(&code)
+		if err != nil {
+			return nil, err
+		}
+
+		permissions = append(permissions, code)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// AddPermissionsForPrincipal grants the permissions with the given codes
+// directly to the principal with the given ID, creating any permissions that
+// do not exist yet. Permissions that the principal already has are ignored.
+// The permissions are granted in a single transaction, so either all of them
+// are granted or none are.
+func (s *PermissionStore) AddPermissionsForPrincipal(ctx context.Context, principalID string, codes ...string) error {
+	query := `
+		WITH p AS (
+			INSERT INTO permissions (code)
+			VALUES ($2)
+			ON CONFLICT (code) DO UPDATE SET code = EXCLUDED.code
+			RETURNING id
+		)
+		INSERT INTO principals_permissions (principal_id, permission_id)
+		SELECT $1, p.id FROM p
+		ON CONFLICT DO NOTHING`
+
+	return s.inTx(ctx, func(ctx context.Context, q Querier) error {
+		return execEach(ctx, q, query, principalID, codes)
+	})
+}
+
+// RemovePermissionsForPrincipal takes the permissions with the given codes
+// that were granted directly to the principal with the given ID away from them
+// in a single transaction. Permissions that the principal does not have
+// directly are ignored, and those that they have through a role are kept.
+func (s *PermissionStore) RemovePermissionsForPrincipal(ctx context.Context, principalID string, codes ...string) error {
+	query := `
+		DELETE FROM principals_permissions
+		WHERE principal_id = $1
+		AND permission_id = (SELECT p.id FROM permissions p WHERE p.code = $2)`
+
+	return s.inTx(ctx, func(ctx context.Context, q Querier) error {
+		return execEach(ctx, q, query, principalID, codes)
+	})
+}
+
+// AddRolesForPrincipal gives the roles with the given names to the principal
+// with the given ID, creating any roles that do not exist yet. Roles that the
+// principal already has are ignored. The roles are given in a single
+// transaction, so either all of them are given or none are.
+func (s *PermissionStore) AddRolesForPrincipal(ctx context.Context, principalID string, roles ...string) error {
+	query := `
+		WITH r AS (
+			INSERT INTO roles (name)
+			VALUES ($2)
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id
+		)
+		INSERT INTO principals_roles (principal_id, role_id)
+		SELECT $1, r.id FROM r
+		ON CONFLICT DO NOTHING`
+
+	return s.inTx(ctx, func(ctx context.Context, q Querier) error {
+		return execEach(ctx, q, query, principalID, roles)
+	})
+}
+
+// RemoveRolesForPrincipal takes the roles with the given names away from the
+// principal with the given ID in a single transaction. Roles that the
+// principal does not have are ignored.
+func (s *PermissionStore) RemoveRolesForPrincipal(ctx context.Context, principalID string, roles ...string) error {
+	query := `
+		DELETE FROM principals_roles
+		WHERE principal_id = $1
+		AND role_id = (SELECT r.id FROM roles r WHERE r.name = $2)`
+
+	return s.inTx(ctx, func(ctx context.Context, q Querier) error {
+		return execEach(ctx, q, query, principalID, roles)
+	})
+}
+
+// AddPermissionsForRole grants the permissions with the given codes to the
+// role with the given name, creating the role and any permissions that do not
+// exist yet. The permissions are granted in a single transaction, so either
+// all of them are granted or none are.
+func (s *PermissionStore) AddPermissionsForRole(ctx context.Context, role string, codes ...string) error {
+	roleQuery := `
+		INSERT INTO roles (name)
+		VALUES ($1)
+		ON CONFLICT (name) DO NOTHING`
+
+	query := `
+		WITH p AS (
+			INSERT INTO permissions (code)
+			VALUES ($2)
+			ON CONFLICT (code) DO UPDATE SET code = EXCLUDED.code
+			RETURNING id
+		)
+		INSERT INTO roles_permissions (role_id, permission_id)
+		SELECT r.id, p.id FROM roles r, p WHERE r.name = $1
+		ON CONFLICT DO NOTHING`
+
+	return s.inTx(ctx, func(ctx context.Context, q Querier) error {
+		_, err := q.ExecContext(ctx, roleQuery, role)
+		if err != nil {
+			return err
+		}
+
+		return execEach(ctx, q, query, role, codes)
+	})
+}
+
+// RemovePermissionsForRole takes the permissions with the given codes away
+// from the role with the given name in a single transaction. Permissions that
+// the role does not have are ignored.
+func (s *PermissionStore) RemovePermissionsForRole(ctx context.Context, role string, codes ...string) error {
+	query := `
+		DELETE FROM roles_permissions
+		WHERE role_id = (SELECT r.id FROM roles r WHERE r.name = $1)
+		AND permission_id = (SELECT p.id FROM permissions p WHERE p.code = $2)`
+
+	return s.inTx(ctx, func(ctx context.Context, q Querier) error {
+		return execEach(ctx, q, query, role, codes)
+	})
+}
+
+// txBeginner is implemented by a Querier that can begin a transaction, such as
+// a *sql.DB or a *TracedDB.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// inTx calls fn with a transaction begun on s.DB, which is committed if fn
+// succeeds and rolled back otherwise. The ctx passed to fn has the store's
+// query timeout applied, so fn must run its statements with it. The
+// statements in the transaction are not traced, even if s.DB is a *TracedDB.
+// If s.DB cannot begin a transaction, for example because it is a *sql.Tx,
+// then fn is called with s.DB itself so that the statements are part of the
+// caller's transaction.
+func (s *PermissionStore) inTx(ctx context.Context, fn func(ctx context.Context, q Querier) error) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	db, ok := s.DB.(txBeginner)
+	if !ok {
+		return fn(ctx, s.DB)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// execEach executes query on q once for each of the values, passing it id as
+// the first argument and the value as the second.
+func execEach(ctx context.Context, q Querier, query, id string, values []string) error {
+	for _, value := range values {
+		_, err := q.ExecContext(ctx, query, id, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPermissionStoreGrants(t *testing.T) {
+	errFailed := errors.New("statement failed")
+
+	tests := []struct {
+		name          string
+		results       []fakeResult
+		grant         func(s *PermissionStore) error
+		wantErr       error
+		wantCalls     int
+		wantCommits   int
+		wantRollbacks int
+		wantQuery     string
+	}{
+		{
+			name:    "permissions for principal",
+			results: []fakeResult{{rowsAffected: 1}, {rowsAffected: 1}},
+			grant: func(s *PermissionStore) error {
+				return s.AddPermissionsForPrincipal(context.Background(), "user-1", "movies:read", "movies:write")
+			},
+			wantCalls:   2,
+			wantCommits: 1,
+			wantQuery:   "INSERT INTO permissions",
+		},
+		{
+			name:    "roles for principal",
+			results: []fakeResult{{rowsAffected: 1}},
+			grant: func(s *PermissionStore) error {
+				return s.AddRolesForPrincipal(context.Background(), "user-1", "editor")
+			},
+			wantCalls:   1,
+			wantCommits: 1,
+			wantQuery:   "INSERT INTO roles",
+		},
+		{
+			name:    "permissions for role",
+			results: []fakeResult{{rowsAffected: 1}, {rowsAffected: 1}, {rowsAffected: 1}},
+			grant: func(s *PermissionStore) error {
+				return s.AddPermissionsForRole(context.Background(), "editor", "movies:read", "movies:write")
+			},
+			wantCalls:   3,
+			wantCommits: 1,
+			wantQuery:   "INSERT INTO permissions",
+		},
+		{
+			name:    "remove permissions for principal",
+			results: []fakeResult{{rowsAffected: 1}, {rowsAffected: 0}},
+			grant: func(s *PermissionStore) error {
+				return s.RemovePermissionsForPrincipal(context.Background(), "user-1", "movies:read", "movies:write")
+			},
+			wantCalls:   2,
+			wantCommits: 1,
+			wantQuery:   "DELETE FROM principals_permissions",
+		},
+		{
+			name:    "remove roles for principal",
+			results: []fakeResult{{rowsAffected: 1}},
+			grant: func(s *PermissionStore) error {
+				return s.RemoveRolesForPrincipal(context.Background(), "user-1", "editor")
+			},
+			wantCalls:   1,
+			wantCommits: 1,
+			wantQuery:   "DELETE FROM principals_roles",
+		},
+		{
+			name:    "remove permissions for role",
+			results: []fakeResult{{rowsAffected: 1}, {rowsAffected: 1}},
+			grant: func(s *PermissionStore) error {
+				return s.RemovePermissionsForRole(context.Background(), "editor", "movies:read", "movies:write")
+			},
+			wantCalls:   2,
+			wantCommits: 1,
+			wantQuery:   "DELETE FROM roles_permissions",
+		},
+		{
+			name:    "failure part way through is rolled back",
+			results: []fakeResult{{rowsAffected: 1}, {err: errFailed}},
+			grant: func(s *PermissionStore) error {
+				return s.AddPermissionsForPrincipal(context.Background(), "user-1", "movies:read", "movies:write", "movies:delete")
+			},
+			wantErr:       errFailed,
+			wantCalls:     2,
+			wantRollbacks: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, tt.results...)
+
+			err := tt.grant(NewPermissionStore(db))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+
+			if len(fake.calls) != tt.wantCalls {
+				t.Errorf("got %d statements; want %d", len(fake.calls), tt.wantCalls)
+			}
+			if fake.callsInTx != len(fake.calls) {
+				t.Errorf("got %d of %d statements in a transaction; want all of them", fake.callsInTx, len(fake.calls))
+			}
+			for i, call := range fake.calls {
+				if !call.hasDeadline {
+					t.Errorf("got statement %d run without a timeout", i+1)
+				}
+			}
+			if fake.commits != tt.wantCommits || fake.rollbacks != tt.wantRollbacks {
+				t.Errorf("got %d commits and %d rollbacks; want %d and %d",
+					fake.commits, fake.rollbacks, tt.wantCommits, tt.wantRollbacks)
+			}
+			if tt.wantQuery != "" && !strings.Contains(fake.calls[len(fake.calls)-1].query, tt.wantQuery) {
+				t.Errorf("got query %q; want it to contain %q", fake.calls[len(fake.calls)-1].query, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestPermissionStoreGrantsInCallersTransaction(t *testing.T) {
+	db, fake := newFakeDB(t, fakeResult{rowsAffected: 1}, fakeResult{rowsAffected: 1})
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewPermissionStore(tx).AddRolesForPrincipal(context.Background(), "user-1", "editor", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fake.commits != 0 {
+		t.Errorf("got %d commits before the caller committed; want 0", fake.commits)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fake.commits != 1 || fake.callsInTx != 2 {
+		t.Errorf("got %d commits and %d statements in a transaction; want 1 and 2", fake.commits, fake.callsInTx)
+	}
+	for i, call := range fake.calls {
+		if !call.hasDeadline {
+			t.Errorf("got statement %d run without a timeout", i+1)
+		}
+	}
+}
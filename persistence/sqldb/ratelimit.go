@@ -0,0 +1,115 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+)
+
+// DefaultRateLimitTable is the name of the table used by a RateLimitStore if
+// no other name is given.
+const DefaultRateLimitTable = "rate_limits"
+
+// RateLimitStore is a webapp.RateLimitStore that keeps a token bucket for each
+// client in a SQL database, so that every replica of a service shares the same
+// budget per client and the budgets survive a redeploy. The queries use
+// PostgreSQL syntax and expect a table such as the following to exist:
+//
+//	CREATE TABLE IF NOT EXISTS rate_limits (
+//	    key        text PRIMARY KEY,
+//	    tokens     double precision NOT NULL,
+//	    updated_at timestamp with time zone NOT NULL
+//	);
+//
+// The DB can be a *sql.DB or a *TracedDB if the queries should be traced.
+type RateLimitStore struct {
+	DB    Querier
+	Table string
+}
+
+// NewRateLimitStore returns a pointer to a new RateLimitStore that uses the
+// DefaultRateLimitTable in db.
+func NewRateLimitStore(db Querier) *RateLimitStore {
+	return &RateLimitStore{DB: db, Table: DefaultRateLimitTable}
+}
+
+// Allow implements the webapp.RateLimitStore interface. The bucket is refilled
+// and the tokens taken in a single statement so that concurrent requests from
+// different replicas cannot both take the last tokens. If there are not enough
+// tokens available, then the row is not updated and no row is returned. A new
+// client's bucket is only created if the request is allowed, so a request that
+// costs more than the burst never leaves the client owing tokens.
+func (s *RateLimitStore) Allow(ctx context.Context, key string, rps float64, burst, cost int) (access.RateLimitResult, error) {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (key, tokens, updated_at)
+		SELECT $1, $2::double precision - $4::double precision, now()
+		WHERE $2::double precision >= $4::double precision
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = LEAST($2::double precision, %[1]s.tokens +
+				EXTRACT(EPOCH FROM now() - %[1]s.updated_at)::double precision * $3::double precision) - $4::double precision,
+			updated_at = now()
+		WHERE LEAST($2::double precision, %[1]s.tokens +
+			EXTRACT(EPOCH FROM now() - %[1]s.updated_at)::double precision * $3::double precision) >= $4::double precision
+		RETURNING tokens`, s.table())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var tokens float64
+	err := s.DB.QueryRowContext(ctx, query, key, float64(burst), rps, float64(cost)).Scan(&tokens)
+	if err == nil {
+		return access.NewRateLimitResult(tokens >= 0, tokens, rps, burst, cost), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return access.RateLimitResult{}, err
+	}
+
+	// The request was not allowed, so find out how many tokens the client has
+	// to work out how long they need to wait.
+	query = fmt.Sprintf(`
+		SELECT LEAST($2::double precision, tokens +
+			EXTRACT(EPOCH FROM now() - updated_at)::double precision * $3::double precision)
+		FROM %s
+		WHERE key = $1`, s.table())
+
+	err = s.DB.QueryRowContext(ctx, query, key, float64(burst), rps).Scan(&tokens)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// The client has no bucket yet, so it would be full.
+		tokens = float64(burst)
+	case err != nil:
+		return access.RateLimitResult{}, err
+	}
+
+	return access.NewRateLimitResult(false, tokens, rps, burst, cost), nil
+}
+
+// DeleteIdle deletes the buckets of any clients that have not made a request
+// for at least the given duration. It should be called periodically to stop
+// the table from growing indefinitely.
+func (s *RateLimitStore) DeleteIdle(ctx context.Context, idle time.Duration) (int64, error) {
+	query := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE updated_at < $1`, s.table())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, query, time.Now().Add(-idle))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *RateLimitStore) table() string {
+	if s.Table == "" {
+		return DefaultRateLimitTable
+	}
+	return s.Table
+}
@@ -0,0 +1,100 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitStoreAllow(t *testing.T) {
+	tests := []struct {
+		name           string
+		burst          int
+		cost           int
+		results        []fakeResult
+		wantAllowed    bool
+		wantRemaining  int
+		wantRetryAfter time.Duration
+		wantCalls      int
+	}{
+		{
+			name:  "allowed",
+			burst: 10,
+			cost:  1,
+			results: []fakeResult{
+				{columns: []string{"tokens"}, rows: [][]driver.Value{{4.0}}},
+			},
+			wantAllowed:   true,
+			wantRemaining: 4,
+			wantCalls:     1,
+		},
+		{
+			name:  "denied",
+			burst: 10,
+			cost:  1,
+			results: []fakeResult{
+				{columns: []string{"tokens"}},
+				{columns: []string{"tokens"}, rows: [][]driver.Value{{0.5}}},
+			},
+			wantAllowed:    false,
+			wantRemaining:  0,
+			wantRetryAfter: 500 * time.Millisecond,
+			wantCalls:      2,
+		},
+		{
+			name:  "new client costing more than the burst",
+			burst: 10,
+			cost:  20,
+			results: []fakeResult{
+				{columns: []string{"tokens"}},
+				{columns: []string{"tokens"}},
+			},
+			wantAllowed:   false,
+			wantRemaining: 10,
+			wantCalls:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, tt.results...)
+			store := NewRateLimitStore(db)
+
+			result, err := store.Allow(context.Background(), "client-1", 1, tt.burst, tt.cost)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Allowed != tt.wantAllowed {
+				t.Errorf("got allowed %t; want %t", result.Allowed, tt.wantAllowed)
+			}
+			if result.Remaining != tt.wantRemaining {
+				t.Errorf("got remaining %d; want %d", result.Remaining, tt.wantRemaining)
+			}
+			if result.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("got retry after %s; want %s", result.RetryAfter, tt.wantRetryAfter)
+			}
+			if len(fake.calls) != tt.wantCalls {
+				t.Fatalf("got %d queries; want %d", len(fake.calls), tt.wantCalls)
+			}
+
+			call := fake.calls[0]
+			if !strings.Contains(call.query, "INSERT INTO rate_limits") {
+				t.Errorf("got query %q; want it to use the rate_limits table", call.query)
+			}
+			// A new client's bucket is only created if it can afford the
+			// request, so that a denied request takes no tokens.
+			if !strings.Contains(call.query, "WHERE $2::double precision >= $4::double precision") {
+				t.Errorf("got query %q; want the insert to be guarded by the cost", call.query)
+			}
+			want := []driver.Value{"client-1", float64(tt.burst), 1.0, float64(tt.cost)}
+			for i := range want {
+				if call.args[i] != want[i] {
+					t.Errorf("got argument %d %v; want %v", i+1, call.args[i], want[i])
+				}
+			}
+		})
+	}
+}
@@ -50,7 +50,7 @@ func NewUniqueConstraintErr(table, column string, columns ...string) *ErrUniqueC
 // it, sets some important properties and then tests the connection before
 // returning a pointer to the connection and an error.
 func OpenDB(cfg config.SqlDB) (*sql.DB, error) {
-	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	db, err := sql.Open(cfg.Driver, cfg.DSN.Value())
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,174 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeResult is the scripted result of a single query or statement run against
+// a fakeDB. Queries return the columns and rows, and statements return
+// rowsAffected. If err is set, then it is returned instead.
+type fakeResult struct {
+	columns      []string
+	rows         [][]driver.Value
+	rowsAffected int64
+	err          error
+}
+
+// fakeCall records a query or statement run against a fakeDB and whether its
+// context had a deadline.
+type fakeCall struct {
+	query       string
+	args        []driver.Value
+	hasDeadline bool
+}
+
+// fakeDB is a database/sql driver that returns scripted results in order, so
+// that the stores can be tested without a database. It records each call and
+// whether transactions were committed or rolled back.
+type fakeDB struct {
+	mu         sync.Mutex
+	results    []fakeResult
+	calls      []fakeCall
+	commits    int
+	rollbacks  int
+	inTx       bool
+	callsInTx  int
+	unexpected error
+}
+
+// newFakeDB returns a *sql.DB backed by a new fakeDB that returns results in
+// order.
+func newFakeDB(t *testing.T, results ...fakeResult) (*sql.DB, *fakeDB) {
+	t.Helper()
+
+	fake := &fakeDB{results: results}
+	db := sql.OpenDB(fake)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+		if fake.unexpected != nil {
+			t.Error(fake.unexpected)
+		}
+		if len(fake.results) > 0 {
+			t.Errorf("%d scripted results were not used", len(fake.results))
+		}
+	})
+
+	return db, fake
+}
+
+func (f *fakeDB) Connect(ctx context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                            { return nil }
+
+func (f *fakeDB) next(ctx context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	_, hasDeadline := ctx.Deadline()
+	f.calls = append(f.calls, fakeCall{query: query, args: values, hasDeadline: hasDeadline})
+	if f.inTx {
+		f.callsInTx++
+	}
+
+	if len(f.results) == 0 {
+		f.unexpected = errors.New("unexpected query: " + query)
+		return fakeResult{}, f.unexpected
+	}
+
+	result := f.results[0]
+	f.results = f.results[1:]
+
+	return result, result.err
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.db.inTx = true
+
+	return &fakeTx{db: c.db}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, err := c.db.next(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: result.columns, rows: result.rows}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.db.next(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(result.rowsAffected), nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	tx.db.inTx = false
+	tx.db.commits++
+
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	tx.db.inTx = false
+	tx.db.rollbacks++
+
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
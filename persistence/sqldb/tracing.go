@@ -0,0 +1,105 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the OpenTelemetry tracer used by this package.
+const tracerName = "github.com/m5lapp/go-service-toolkit/persistence/sqldb"
+
+// Querier is the set of context-aware query methods shared by *sql.DB,
+// *sql.Tx and *TracedDB. The stores in this package accept a Querier so that
+// they can be given any of them.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// TracedDB wraps a *sql.DB so that each query made through its ExecContext(),
+// QueryContext() and QueryRowContext() methods is recorded as an OpenTelemetry
+// client span, using the global tracer provider installed by tracing.Setup().
+// The other methods of the *sql.DB, including those without a context, are
+// available as normal but are not traced.
+type TracedDB struct {
+	*sql.DB
+	system string
+}
+
+// TraceDB returns a TracedDB wrapping db. The system parameter identifies the
+// type of database in the spans, such as postgresql or mysql.
+func TraceDB(db *sql.DB, system string) *TracedDB {
+	return &TracedDB{DB: db, system: system}
+}
+
+// ExecContext executes a query without returning any rows.
+func (db *TracedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := db.startSpan(ctx, query)
+	defer span.End()
+
+	result, err := db.DB.ExecContext(ctx, query, args...)
+	recordSpanError(span, err)
+
+	return result, err
+}
+
+// QueryContext executes a query that returns rows. The span ends when the
+// query has been executed, not when the rows have been read.
+func (db *TracedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := db.startSpan(ctx, query)
+	defer span.End()
+
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	recordSpanError(span, err)
+
+	return rows, err
+}
+
+// QueryRowContext executes a query that is expected to return at most one row.
+// An error from running the query is recorded on the span. Errors that are
+// only reported when the row is scanned, including sql.ErrNoRows, happen after
+// the span has ended and so are not recorded.
+func (db *TracedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := db.startSpan(ctx, query)
+	defer span.End()
+
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	recordSpanError(span, row.Err())
+
+	return row
+}
+
+// startSpan starts a span for the given query. The span is named after the
+// operation, such as SELECT, and holds the statement without any of its
+// arguments so that no values are leaked into the traces.
+func (db *TracedDB) startSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation := "query"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", db.system),
+			attribute.String("db.operation", operation),
+			attribute.String("db.statement", strings.Join(strings.Fields(query), " ")),
+		),
+	)
+}
+
+// recordSpanError records err on span and marks it as failed if err is not
+// nil.
+func recordSpanError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
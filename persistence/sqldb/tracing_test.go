@@ -0,0 +1,121 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracedDB(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prev)
+	})
+
+	errFailed := errors.New("statement failed")
+
+	tests := []struct {
+		name          string
+		result        fakeResult
+		run           func(ctx context.Context, db *TracedDB) error
+		wantName      string
+		wantStatement string
+		wantStatus    codes.Code
+	}{
+		{
+			name:   "query row",
+			result: fakeResult{columns: []string{"title"}, rows: [][]driver.Value{{"Dune"}}},
+			run: func(ctx context.Context, db *TracedDB) error {
+				var title string
+				return db.QueryRowContext(ctx, "SELECT title\n\t\tFROM movies WHERE id = $1", 1).Scan(&title)
+			},
+			wantName:      "SELECT",
+			wantStatement: "SELECT title FROM movies WHERE id = $1",
+			wantStatus:    codes.Unset,
+		},
+		{
+			name:   "query",
+			result: fakeResult{columns: []string{"title"}},
+			run: func(ctx context.Context, db *TracedDB) error {
+				rows, err := db.QueryContext(ctx, "select title from movies")
+				if err != nil {
+					return err
+				}
+				return rows.Close()
+			},
+			wantName:      "SELECT",
+			wantStatement: "select title from movies",
+			wantStatus:    codes.Unset,
+		},
+		{
+			name:   "failed exec",
+			result: fakeResult{err: errFailed},
+			run: func(ctx context.Context, db *TracedDB) error {
+				_, err := db.ExecContext(ctx, "UPDATE movies SET title = $1", "Dune")
+				return err
+			},
+			wantName:      "UPDATE",
+			wantStatement: "UPDATE movies SET title = $1",
+			wantStatus:    codes.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+
+			db, _ := newFakeDB(t, tt.result)
+			traced := TraceDB(db, "postgresql")
+
+			ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+			err := tt.run(ctx, traced)
+			parent.End()
+
+			if (err != nil) != (tt.wantStatus == codes.Error) {
+				t.Fatalf("got error %v; want error %t", err, tt.wantStatus == codes.Error)
+			}
+
+			spans := exporter.GetSpans()
+			if len(spans) != 2 {
+				t.Fatalf("got %d spans; want 2", len(spans))
+			}
+
+			span := spans[0]
+			if span.Name != tt.wantName {
+				t.Errorf("got span name %q; want %q", span.Name, tt.wantName)
+			}
+			if span.SpanKind != trace.SpanKindClient {
+				t.Errorf("got span kind %s; want %s", span.SpanKind, trace.SpanKindClient)
+			}
+			if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+				t.Errorf("got parent span %s; want %s", span.Parent.SpanID(), parent.SpanContext().SpanID())
+			}
+			if span.Status.Code != tt.wantStatus {
+				t.Errorf("got span status %s; want %s", span.Status.Code, tt.wantStatus)
+			}
+
+			attrs := make(map[string]string)
+			for _, attr := range span.Attributes {
+				attrs[string(attr.Key)] = attr.Value.Emit()
+			}
+			if attrs["db.system"] != "postgresql" {
+				t.Errorf("got db.system %q; want %q", attrs["db.system"], "postgresql")
+			}
+			if attrs["db.statement"] != tt.wantStatement {
+				t.Errorf("got db.statement %q; want %q", attrs["db.statement"], tt.wantStatement)
+			}
+		})
+	}
+}
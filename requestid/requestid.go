@@ -0,0 +1,61 @@
+// Package requestid generates and carries the IDs used to correlate the log
+// records, responses and outgoing calls that result from a single request. It
+// is kept separate from the webapp package so that clients such as jsonz can
+// forward the ID without depending on the server code.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Header is the HTTP header that carries the request ID.
+const Header = "X-Request-ID"
+
+// MaxLength is the maximum length of a request ID accepted from a client.
+const MaxLength = 128
+
+type contextKey struct{}
+
+// New returns a new random request ID made up of 32 hexadecimal characters. It
+// panics if the operating system's random number generator fails, as there is
+// nothing sensible that a request could do without it.
+func New() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether id is acceptable as a request ID from a client. It
+// must be between 1 and MaxLength characters long and only contain printable
+// ASCII characters so that it cannot be used to inject anything into the logs.
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewContext returns a copy of ctx that carries the request ID id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, or an empty string if it
+// does not have one.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,63 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{"hexadecimal", "0123456789abcdef0123456789abcdef", true},
+		{"printable ASCII", "req_1-2.3:4/5=6", true},
+		{"one character", "a", true},
+		{"maximum length", strings.Repeat("a", MaxLength), true},
+		{"empty", "", false},
+		{"over maximum length", strings.Repeat("a", MaxLength+1), false},
+		{"space", "req 1", false},
+		{"newline", "req-1\nlevel=ERROR", false},
+		{"tab", "req\t1", false},
+		{"null byte", "req\x001", false},
+		{"delete", "req\x7f1", false},
+		{"non-ASCII", "req-é", false},
+		{"emoji", "req-🙂", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Valid(tt.id); got != tt.want {
+				t.Errorf("got %t for %q; want %t", got, tt.id, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	seen := make(map[string]bool)
+
+	for i := 0; i < 100; i++ {
+		id := New()
+		if len(id) != 32 || !Valid(id) {
+			t.Fatalf("got invalid ID %q; want 32 valid characters", id)
+		}
+		if seen[id] {
+			t.Fatalf("got ID %q twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != "" {
+		t.Errorf("got ID %q from a context without one; want none", got)
+	}
+
+	ctx := NewContext(context.Background(), "req-1")
+	if got := FromContext(ctx); got != "req-1" {
+		t.Errorf("got ID %q; want %q", got, "req-1")
+	}
+}
@@ -2,14 +2,26 @@ package jsonz
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
+
+	"github.com/m5lapp/go-service-toolkit/requestid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracerName is the name of the OpenTelemetry tracer used by this package.
+const tracerName = "github.com/m5lapp/go-service-toolkit/serialisation/jsonz"
+
 const (
 	errorMsgStatus = "http status code (%d) for %s is not in range %d to %d"
 
@@ -138,6 +150,49 @@ func WriteJSendError(w http.ResponseWriter, status int, headers http.Header,
 // decoding the JSendResponseRaw.Data field if required.
 func RequestJSend(method, url string, tOut time.Duration, requestBody any,
 ) (*http.Response, *JSendResponseRaw, error) {
+	return RequestJSendContext(context.Background(), method, url, tOut, requestBody)
+}
+
+// spanURL returns rawURL without its query string, fragment or user info, so
+// that tokens and personal data in them are not sent to the tracing backend.
+func spanURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return u.String()
+}
+
+// RequestJSendContext is the same as RequestJSend, except that the request is
+// made with the given context. If ctx carries a request ID, such as the one
+// stored by the webapp.RequestID middleware, then it is forwarded in the
+// X-Request-ID header so that the call can be correlated with the request that
+// caused it. The request is also recorded as an OpenTelemetry client span and
+// the trace context is propagated in the traceparent header.
+func RequestJSendContext(ctx context.Context, method, url string, tOut time.Duration,
+	requestBody any) (_ *http.Response, _ *JSendResponseRaw, err error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "HTTP "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", method),
+			attribute.String("url.full", spanURL(url)),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	var reqBody io.Reader
 
 	// If a request body has been provided, attempt to marshal it into JSON.
@@ -151,11 +206,15 @@ func RequestJSend(method, url string, tOut time.Duration, requestBody any,
 	}
 
 	// Create and prepare the HTTP request.
-	req, err := http.NewRequest(method, url, reqBody)
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return nil, nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	// Create an HTTP client and send the request.
 	client := http.Client{Timeout: tOut}
@@ -163,6 +222,7 @@ func RequestJSend(method, url string, tOut time.Duration, requestBody any,
 	if err != nil {
 		return nil, nil, err
 	}
+	span.SetAttributes(attribute.Int("http.response.status_code", httpResp.StatusCode))
 
 	// Decode the response body to get the JSend response status.
 	jSendBody := &JSendResponseRaw{}
@@ -0,0 +1,84 @@
+// Package tracing sets up OpenTelemetry distributed tracing for a service. Once
+// Setup() has been called, the spans created by the webapp.Trace middleware,
+// jsonz.RequestJSendContext(), sqldb.TraceDB and mailer.Mailer are exported to
+// the configured backend, and trace context is propagated between services
+// using the W3C traceparent and baggage headers.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ShutdownFunc flushes any spans that have not been exported yet and stops the
+// exporter. It should be called when the service is shutting down.
+type ShutdownFunc func(ctx context.Context) error
+
+// Setup creates the exporter given by cfg and installs a tracer provider that
+// uses it as the global OpenTelemetry tracer provider. The W3C trace context
+// and baggage propagators are always installed, so that trace context is
+// passed through the service even if it is not exporting spans itself.
+func Setup(ctx context.Context, cfg config.Tracing) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch cfg.Exporter {
+	case config.TracingExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case config.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case config.TracingExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.OTLPEndpoint)}
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter: %s", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s tracing exporter: %w", cfg.Exporter, err)
+	}
+
+	tp := NewTracerProvider(cfg, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// NewTracerProvider returns a tracer provider for the service named in cfg that
+// samples according to cfg.SampleRatio and processes spans with the given span
+// processors. It is used by Setup() and can be used in tests along with an
+// in-memory exporter, for example:
+//
+//	exporter := tracetest.NewInMemoryExporter()
+//	otel.SetTracerProvider(tracing.NewTracerProvider(cfg, sdktrace.WithSyncer(exporter)))
+func NewTracerProvider(cfg config.Tracing, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res := resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))
+
+	// Follow the caller's sampling decision if there is one, so that traces are
+	// not broken up between services.
+	sampler := sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))
+
+	opts = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
+	}, opts...)
+
+	return sdktrace.NewTracerProvider(opts...)
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewTracerProvider(t *testing.T) {
+	sampledParent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+
+	tests := []struct {
+		name        string
+		sampleRatio float64
+		parent      trace.SpanContext
+		wantSpans   int
+	}{
+		{"always sampled", 1, trace.SpanContext{}, 1},
+		{"never sampled", 0, trace.SpanContext{}, 0},
+		{"sampled by the caller", 0, sampledParent, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tp := NewTracerProvider(config.Tracing{ServiceName: "books-api", SampleRatio: tt.sampleRatio},
+				sdktrace.WithSyncer(exporter))
+			defer tp.Shutdown(context.Background())
+
+			ctx := trace.ContextWithRemoteSpanContext(context.Background(), tt.parent)
+			_, span := tp.Tracer("test").Start(ctx, "operation")
+			span.End()
+
+			spans := exporter.GetSpans()
+			if len(spans) != tt.wantSpans {
+				t.Fatalf("got %d spans; want %d", len(spans), tt.wantSpans)
+			}
+			if tt.wantSpans == 0 {
+				return
+			}
+
+			if tt.parent.IsValid() && spans[0].Parent.SpanID() != tt.parent.SpanID() {
+				t.Errorf("got parent span %s; want %s", spans[0].Parent.SpanID(), tt.parent.SpanID())
+			}
+
+			var serviceName string
+			for _, attr := range spans[0].Resource.Attributes() {
+				if attr.Key == "service.name" {
+					serviceName = attr.Value.AsString()
+				}
+			}
+			if serviceName != "books-api" {
+				t.Errorf("got service name %q; want %q", serviceName, "books-api")
+			}
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.Tracing{Exporter: config.TracingExporterNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("got error %v shutting down; want none", err)
+	}
+
+	_, err = Setup(context.Background(), config.Tracing{Exporter: "zipkin"})
+	if err == nil {
+		t.Error("got no error for an unsupported exporter")
+	}
+}
@@ -0,0 +1,132 @@
+package webapp
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+)
+
+// combinedTimeFormat is the timestamp layout used by the Apache combined log
+// format.
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLog is a middleware function that writes a record to the request-scoped
+// logger for each request once it has been handled, so that it includes the
+// request and trace IDs from ContextLogger and any attributes added further in
+// with AddLogAttrs(), such as the user ID. It should be applied outside of any
+// middlewares that may respond to the request themselves, such as RateLimit,
+// so that those responses are logged too, but inside ClientIP so that the real
+// client IP is logged.
+//
+// Requests for one of cfg.ExcludePaths are not logged, and only the fraction
+// cfg.SampleRate of the rest are, although responses with a 5xx status code are
+// always logged.
+func (app *WebApp) AccessLog(cfg config.AccessLog, next http.Handler) http.Handler {
+	if !cfg.Active {
+		return next
+	}
+
+	excluded := make(map[string]bool)
+	for _, path := range cfg.ExcludePaths {
+		excluded[path] = true
+	}
+
+	// Each AccessLog middleware has its own source for sampling rather than
+	// contending for the global one with the rest of the program.
+	var sampleMu sync.Mutex
+	sampleRand := rand.New(rand.NewSource(time.Now().UnixNano()))
+	sampled := func() bool {
+		if cfg.SampleRate >= 1 {
+			return true
+		}
+
+		sampleMu.Lock()
+		defer sampleMu.Unlock()
+
+		return sampleRand.Float64() < cfg.SampleRate
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if excluded[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+
+		r, _ = withRouteHolder(r)
+		r, _ = withLoggerHolder(r)
+		rec, r := RecordResponse(w, r)
+
+		next.ServeHTTP(rec.ResponseWriter(), r)
+
+		status := rec.StatusOrOK()
+
+		if status < http.StatusInternalServerError && !sampled() {
+			return
+		}
+
+		// The route is logged below even if it did not match, so the logger
+		// is taken without the route that RequestLogger() would add.
+		logger := storedLogger(r.Context(), app.Logger)
+
+		if cfg.Format == config.AccessLogCombined {
+			logger.LogAttrs(r.Context(), slog.LevelInfo, combinedLogLine(r, start, status, rec.BytesWritten()))
+			return
+		}
+
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "request",
+			slog.String("method", r.Method),
+			slog.String("route", routeLabel(r)),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Duration("ttfb", rec.TimeToFirstByte()),
+			slog.Int64("bytes_in", rec.BytesRead()),
+			slog.Int64("bytes_out", rec.BytesWritten()),
+			slog.String("client_ip", ClientIPFromRequest(r)),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	})
+}
+
+// combinedLogLine formats a request in the Apache combined log format:
+//
+//	%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-Agent}i"
+func combinedLogLine(r *http.Request, start time.Time, status int, bytes int64) string {
+	size := "-"
+	if bytes > 0 {
+		size = strconv.FormatInt(bytes, 10)
+	}
+
+	user := "-"
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		user = username
+	}
+
+	return fmt.Sprintf("%s - %s [%s] %q %d %s %q %q",
+		ClientIPFromRequest(r),
+		user,
+		start.Format(combinedTimeFormat),
+		r.Method+" "+r.URL.RequestURI()+" "+r.Proto,
+		status,
+		size,
+		orDash(r.Referer()),
+		orDash(r.UserAgent()),
+	)
+}
+
+// orDash returns s, or "-" if s is empty, as used for missing values in the
+// Apache log formats.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
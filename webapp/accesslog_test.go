@@ -0,0 +1,118 @@
+package webapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+	"github.com/m5lapp/go-service-toolkit/requestid"
+)
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantMsg string
+	}{
+		{config.AccessLogStructured, "request"},
+		{config.AccessLogCombined, `"GET /v1/movies?page=2 HTTP/1.1" 204 -`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			app := New(config.Server{}, slog.New(slog.NewJSONHandler(&buf, nil)))
+
+			cfg := config.AccessLog{Active: true, SampleRate: 1, Format: tt.format}
+			handler := app.RequestID(app.AccessLog(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})))
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies?page=2", nil)
+			r.Header.Set(requestid.Header, "test-request-id")
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			var record map[string]any
+			err := json.Unmarshal(buf.Bytes(), &record)
+			if err != nil {
+				t.Fatalf("got log output %q; want a single JSON record", buf.String())
+			}
+
+			if msg, _ := record["msg"].(string); !strings.Contains(msg, tt.wantMsg) {
+				t.Errorf("got message %q; want it to contain %q", msg, tt.wantMsg)
+			}
+			if got := record["request_id"]; got != "test-request-id" {
+				t.Errorf("got request ID %v; want %q", got, "test-request-id")
+			}
+		})
+	}
+}
+
+func TestAccessLogIncludesRequestLogAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	app := New(config.Server{}, slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	tokens := staticTokenStore{"valid": &TokenPrincipal{Subject: "user-1", Activated: true}}
+	app.HandlerFunc(http.MethodGet, "/v1/movies/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	cfg := config.AccessLog{Active: true, SampleRate: 1, Format: config.AccessLogStructured}
+	handler := app.RequestID(app.AccessLog(cfg, app.ContextLogger(app.Authenticate(nil, tokens, app.Router))))
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies/42", nil)
+	r.Header.Set(requestid.Header, "test-request-id")
+	r.Header.Set("Authorization", "Bearer valid")
+
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	records := logRecords(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("got %d log records; want 1", len(records))
+	}
+
+	record := records[0]
+	for key, want := range map[string]any{
+		"request_id": "test-request-id",
+		"user_id":    "user-1",
+		"route":      "/v1/movies/:id",
+		"status":     float64(http.StatusNoContent),
+	} {
+		if got := record[key]; got != want {
+			t.Errorf("got %s %v; want %v", key, got, want)
+		}
+	}
+	if n := strings.Count(buf.String(), `"route"`); n != 1 {
+		t.Errorf("got the route logged %d times; want once", n)
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	app := New(config.Server{}, slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	status := http.StatusOK
+	cfg := config.AccessLog{Active: true, SampleRate: 0, Format: config.AccessLogStructured}
+	handler := app.AccessLog(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	for i := 0; i < 10; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/movies", nil))
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("got log output %q with a sample rate of zero; want none", buf.String())
+	}
+
+	// Server errors are always logged.
+	status = http.StatusInternalServerError
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/movies", nil))
+	if got := len(logRecords(t, &buf)); got != 1 {
+		t.Errorf("got %d log records for a server error; want 1", got)
+	}
+}
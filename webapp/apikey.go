@@ -0,0 +1,98 @@
+package webapp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+)
+
+// apiKeyTouchInterval is how often the LastUsedAt time of an API key is
+// updated, so that a busy client does not cause a database write per request.
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyStore stores API keys, such as sqldb.APIKeyStore.
+type APIKeyStore interface {
+	// GetByHash returns the access.APIKey with the given hash, or
+	// access.ErrAPIKeyNotFound if there is not one.
+	GetByHash(ctx context.Context, hash []byte) (*access.APIKey, error)
+	// Touch sets the LastUsedAt time of the APIKey with the given ID.
+	Touch(ctx context.Context, id int64, lastUsedAt time.Time) error
+}
+
+// AuthenticateAPIKey is a middleware function that authenticates requests with
+// an API key in either the X-API-Key header or an Authorization header with the
+// ApiKey scheme, looking it up by its hash in store. The access.APIKey is
+// stored in the request context as its Principal, so the RequirePermission
+// middleware checks its scopes.
+//
+// Requests without an API key are passed through to next so that this can be
+// used alongside the Authenticate middleware, as are requests that already
+// have a Principal, so that an API key cannot replace a bearer token's
+// Principal. Requests with an unknown or expired key are rejected with
+// InvalidCredentialsResponse.
+func (app *WebApp) AuthenticateAPIKey(store APIKeyStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "X-API-Key")
+		w.Header().Add("Vary", "Authorization")
+
+		if _, ok := PrincipalFromRequest(r); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := apiKeyFromRequest(r)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		apiKey, err := store.GetByHash(r.Context(), access.HashAPIKey(key))
+		if err != nil {
+			if errors.Is(err, access.ErrAPIKeyNotFound) {
+				app.InvalidCredentialsResponse(w, r)
+				return
+			}
+			app.ServerErrorResponse(w, r, err)
+			return
+		}
+
+		if apiKey.Expired() {
+			app.InvalidCredentialsResponse(w, r)
+			return
+		}
+
+		r = ContextSetPrincipal(r, apiKey)
+		r = app.AddLogAttrs(r, "user_id", apiKey.PrincipalID, "api_key_id", apiKey.KeyID)
+
+		if time.Since(apiKey.LastUsedAt) > apiKeyTouchInterval {
+			app.BackgroundContext(r.Context(), func(ctx context.Context) {
+				err := store.Touch(ctx, apiKey.KeyID, time.Now())
+				if err != nil {
+					LoggerFromContext(ctx).Error("Unable to update API key last used time",
+						"api_key_id", apiKey.KeyID, "error", err.Error())
+				}
+			})
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiKeyFromRequest returns the API key sent with r, or an empty string if
+// there is not one.
+func apiKeyFromRequest(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key
+	}
+
+	scheme, key, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if found && strings.EqualFold(scheme, "ApiKey") {
+		return strings.TrimSpace(key)
+	}
+
+	return ""
+}
@@ -0,0 +1,190 @@
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+)
+
+type memoryAPIKeyStore struct {
+	mu      sync.Mutex
+	keys    []*access.APIKey
+	touched map[int64]time.Time
+}
+
+func (s *memoryAPIKeyStore) GetByHash(ctx context.Context, hash []byte) (*access.APIKey, error) {
+	for _, key := range s.keys {
+		if bytes.Equal(key.Hash, hash) {
+			return key, nil
+		}
+	}
+	return nil, access.ErrAPIKeyNotFound
+}
+
+func (s *memoryAPIKeyStore) Touch(ctx context.Context, id int64, lastUsedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.touched == nil {
+		s.touched = make(map[int64]time.Time)
+	}
+	s.touched[id] = lastUsedAt
+
+	return nil
+}
+
+type staticPermissionStore map[string]access.Permissions
+
+func (s staticPermissionStore) PermissionsForPrincipal(ctx context.Context, principalID string) (access.Permissions, error) {
+	return s[principalID], nil
+}
+
+func newTestAPIKey(t *testing.T, store *memoryAPIKeyStore, principalID string, scopes []string, expiresAt time.Time) string {
+	t.Helper()
+
+	key, apiKey, err := access.NewAPIKey("test", principalID, "test key", scopes, expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apiKey.KeyID = int64(len(store.keys) + 1)
+	apiKey.PrincipalActivated = principalID != "inactive-user"
+	store.keys = append(store.keys, apiKey)
+
+	return key
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	store := &memoryAPIKeyStore{}
+	valid := newTestAPIKey(t, store, "user-1", []string{"movies:read"}, time.Time{})
+	expired := newTestAPIKey(t, store, "user-1", []string{"movies:read"}, time.Now().Add(-time.Minute))
+	inactive := newTestAPIKey(t, store, "inactive-user", []string{"movies:read"}, time.Time{})
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"X-API-Key header", "X-API-Key", valid, http.StatusNoContent},
+		{"ApiKey authorization scheme", "Authorization", "ApiKey " + valid, http.StatusNoContent},
+		{"unknown key", "X-API-Key", "test_unknown", http.StatusUnauthorized},
+		{"expired key", "X-API-Key", expired, http.StatusUnauthorized},
+		{"key of a deactivated account", "X-API-Key", inactive, http.StatusForbidden},
+		{"no key", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			next := app.RequirePermission("movies:read", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+			handler := app.AuthenticateAPIKey(store, next)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("got status %d; want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyScopesLimitPermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		scopes      []string
+		permissions staticPermissionStore
+		code        string
+		want        int
+	}{
+		{
+			name:        "scope held by owner",
+			scopes:      []string{"movies:read"},
+			permissions: staticPermissionStore{"user-1": {"movies:read", "movies:write"}},
+			code:        "movies:read",
+			want:        http.StatusNoContent,
+		},
+		{
+			name:        "owner permission outside scopes",
+			scopes:      []string{"movies:read"},
+			permissions: staticPermissionStore{"user-1": {"movies:read", "movies:write"}},
+			code:        "movies:write",
+			want:        http.StatusForbidden,
+		},
+		{
+			name:        "scope no longer held by owner",
+			scopes:      []string{"movies:read", "movies:write"},
+			permissions: staticPermissionStore{"user-1": {"movies:read"}},
+			code:        "movies:write",
+			want:        http.StatusForbidden,
+		},
+		{
+			name:   "scope without a permission store",
+			scopes: []string{"movies:write"},
+			code:   "movies:write",
+			want:   http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryAPIKeyStore{}
+			key := newTestAPIKey(t, store, "user-1", tt.scopes, time.Time{})
+
+			app := newTestApp(t)
+			if tt.permissions != nil {
+				app.PermissionStore = tt.permissions
+			}
+
+			next := app.RequirePermission(tt.code, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+			handler := app.AuthenticateAPIKey(store, next)
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/movies", nil)
+			r.Header.Set("X-API-Key", key)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("got status %d; want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKeyKeepsExistingPrincipal(t *testing.T) {
+	store := &memoryAPIKeyStore{}
+	key := newTestAPIKey(t, store, "user-2", []string{"movies:read"}, time.Time{})
+
+	app := newTestApp(t)
+	var got Principal
+	handler := app.AuthenticateAPIKey(store, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromRequest(r)
+	}))
+
+	principal := &TokenPrincipal{Subject: "user-1", Activated: true}
+	r := ContextSetPrincipal(httptest.NewRequest(http.MethodGet, "/v1/movies", nil), principal)
+	r.Header.Set("X-API-Key", key)
+
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got != principal {
+		t.Errorf("got principal %v; want the one set before the API key was checked", got)
+	}
+}
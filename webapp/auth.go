@@ -0,0 +1,215 @@
+package webapp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+)
+
+// Principal is the authenticated identity that made a request. A service can
+// implement it with its own user model and store it in the request context
+// with ContextSetPrincipal() to use the RequireAuthenticatedUser,
+// RequireActivatedUser and RequirePermission middlewares.
+type Principal interface {
+	// ID returns the unique identifier of the principal, such as a user ID.
+	ID() string
+	// IsActivated reports whether the principal's account has been activated.
+	IsActivated() bool
+	// HasPermission reports whether the principal has been granted the
+	// permission with the given code, such as books:write.
+	HasPermission(code string) bool
+}
+
+// ScopedPrincipal is a Principal, such as an access.APIKey, that acts on behalf
+// of another principal with a restricted set of permissions. The
+// RequirePermission middleware only grants a ScopedPrincipal the permissions
+// that it has itself, and if the WebApp has a PermissionStore, then the
+// principal it acts for must also still have them.
+type ScopedPrincipal interface {
+	Principal
+	// IsScoped reports whether the principal's permissions are restricted to
+	// the ones it has itself.
+	IsScoped() bool
+}
+
+// TokenStore looks up opaque bearer tokens, such as session or API tokens that
+// are stored in a database or issued by another service.
+type TokenStore interface {
+	// LookupToken returns the Principal that token was issued to. If the token
+	// is not valid, then the error returned must wrap access.ErrInvalidToken.
+	// If the token cannot be checked right now, then it can wrap
+	// access.ErrUnavailable. Any other error is treated as a server error.
+	LookupToken(ctx context.Context, token string) (Principal, error)
+}
+
+// Authenticate is a middleware function that authenticates requests with a
+// bearer token in the Authorization header and stores the resulting Principal
+// in the request context, where it can be retrieved with
+// PrincipalFromRequest(). Tokens that look like JWTs are validated by jwt, and
+// any others are looked up in store. Either of them can be nil if that kind of
+// token is not accepted.
+//
+// Requests without a bearer token, such as those using another authentication
+// scheme, are passed through to next unchanged so that routes can allow
+// anonymous access and so that this can be combined with other authentication
+// middlewares such as AuthenticateAPIKey. Requests that already have a
+// Principal are also passed through. Requests with an invalid bearer token are
+// rejected with InvalidAuthenticationTokenResponse, and if the token cannot be
+// checked because an error wrapping access.ErrUnavailable is returned, then
+// with ServiceUnavailableResponse. If the request's own context is cancelled
+// or reaches its deadline whilst the token is being checked, such as when the
+// client disconnects, then no response is sent and nothing is logged above the
+// debug level.
+func (app *WebApp) Authenticate(jwt *JWTVerifier, store TokenStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		if _, ok := PrincipalFromRequest(r); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token = strings.TrimSpace(token)
+		if token == "" {
+			app.InvalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		var principal Principal
+		var err error
+
+		switch {
+		case jwt != nil && looksLikeJWT(token):
+			principal, err = jwt.Verify(r.Context(), token)
+		case store != nil:
+			principal, err = store.LookupToken(r.Context(), token)
+		default:
+			err = access.ErrInvalidToken
+		}
+
+		if err != nil {
+			switch {
+			case requestAbandoned(r, err):
+				app.RequestLogger(r).Debug("Authentication abandoned", "error", err.Error())
+			case errors.Is(err, access.ErrInvalidToken):
+				app.RequestLogger(r).Debug("Authentication failed", "error", err.Error())
+				app.InvalidAuthenticationTokenResponse(w, r)
+			case errors.Is(err, access.ErrUnavailable):
+				app.ServiceUnavailableResponse(w, r, err)
+			default:
+				app.ServerErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		r = ContextSetPrincipal(r, principal)
+		r = app.AddLogAttrs(r, "user_id", principal.ID())
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ContextSetPrincipal returns a shallow copy of r with principal stored in its
+// context.
+func ContextSetPrincipal(r *http.Request, principal Principal) *http.Request {
+	return ContextSet(r, principal)
+}
+
+// PrincipalFromRequest returns the Principal stored in the context of r by the
+// Authenticate middleware. The boolean is false if the request was not
+// authenticated.
+func PrincipalFromRequest(r *http.Request) (Principal, bool) {
+	principal, ok := ContextGet[Principal](r)
+	return principal, ok && principal != nil
+}
+
+// RequireAuthenticatedUser is a middleware function that only allows requests
+// with a Principal in their context through to next. Others are rejected with
+// AuthenticationRequiredResponse.
+func (app *WebApp) RequireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, ok := PrincipalFromRequest(r)
+		if !ok {
+			app.AuthenticationRequiredResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RequireActivatedUser is the same as RequireAuthenticatedUser, except that
+// the Principal's account must also be activated. Requests from inactive
+// accounts are rejected with InactiveAccountResponse.
+func (app *WebApp) RequireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFromRequest(r)
+		if !principal.IsActivated() {
+			app.InactiveAccountResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.RequireAuthenticatedUser(fn)
+}
+
+// RequirePermission is the same as RequireActivatedUser, except that the
+// Principal must also have the permission with the given code, either itself
+// or in the WebApp's PermissionStore. A ScopedPrincipal must have it in both
+// places. Requests without it are rejected with NotPermittedResponse. As with
+// Authenticate, requests abandoned whilst the PermissionStore is being checked
+// are not sent a response.
+func (app *WebApp) RequirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFromRequest(r)
+
+		permitted, err := app.hasPermission(r.Context(), principal, code)
+		if err != nil {
+			if requestAbandoned(r, err) {
+				app.RequestLogger(r).Debug("Permission check abandoned", "error", err.Error())
+				return
+			}
+			if errors.Is(err, access.ErrUnavailable) {
+				app.ServiceUnavailableResponse(w, r, err)
+				return
+			}
+			app.ServerErrorResponse(w, r, err)
+			return
+		}
+
+		if !permitted {
+			app.NotPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.RequireActivatedUser(fn)
+}
+
+// requestAbandoned reports whether err resulted from the context of r being
+// cancelled or reaching its deadline, rather than from anything going wrong on
+// the server. Timeouts of calls made on the request's behalf, such as to an auth
+// service, do not count as long as the request itself is still live.
+func requestAbandoned(r *http.Request, err error) bool {
+	return r.Context().Err() != nil &&
+		(errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+}
+
+// looksLikeJWT reports whether token has the three dot separated parts of a
+// JWT in compact serialisation.
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
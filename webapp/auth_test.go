@@ -0,0 +1,275 @@
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+)
+
+type staticTokenStore map[string]Principal
+
+func (s staticTokenStore) LookupToken(ctx context.Context, token string) (Principal, error) {
+	principal, ok := s[token]
+	if !ok {
+		return nil, access.ErrInvalidToken
+	}
+	return principal, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	tokens := staticTokenStore{"valid": &TokenPrincipal{Subject: "user-1", Activated: true}}
+
+	keys := &memoryAPIKeyStore{}
+	apiKey := newTestAPIKey(t, keys, "user-2", nil, time.Time{})
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantPrincipal string
+	}{
+		{"no authorization header", "", http.StatusOK, ""},
+		{"valid bearer token", "Bearer valid", http.StatusOK, "user-1"},
+		{"lower case bearer scheme", "bearer valid", http.StatusOK, "user-1"},
+		{"invalid bearer token", "Bearer invalid", http.StatusUnauthorized, ""},
+		{"empty bearer token", "Bearer ", http.StatusUnauthorized, ""},
+		{"other scheme", "Basic dXNlcjpwYXNz", http.StatusOK, ""},
+		{"API key authenticated first", "ApiKey " + apiKey, http.StatusOK, "user-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+
+			var gotPrincipal string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if principal, ok := PrincipalFromRequest(r); ok {
+					gotPrincipal = principal.ID()
+				}
+			})
+			handler := app.AuthenticateAPIKey(keys, app.Authenticate(nil, tokens, next))
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d; want %d", w.Code, tt.wantStatus)
+			}
+			if gotPrincipal != tt.wantPrincipal {
+				t.Errorf("got principal %q; want %q", gotPrincipal, tt.wantPrincipal)
+			}
+		})
+	}
+}
+
+// blockingStore is a TokenStore and PermissionStore that waits for the
+// request to be abandoned and returns the context's error.
+type blockingStore struct{}
+
+func (blockingStore) LookupToken(ctx context.Context, token string) (Principal, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingStore) PermissionsForPrincipal(ctx context.Context, principalID string) (access.Permissions, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestAbandonedRequestsNotLoggedAsErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		authenticate bool
+		deadline     bool
+	}{
+		{"authentication cancelled", true, false},
+		{"authentication deadline exceeded", true, true},
+		{"permission check cancelled", false, false},
+		{"permission check deadline exceeded", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			app := newTestApp(t)
+			app.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+			app.PermissionStore = blockingStore{}
+
+			called := false
+			next := func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}
+
+			var ctx context.Context
+			var cancel context.CancelFunc
+			if tt.deadline {
+				ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+			} else {
+				ctx, cancel = context.WithCancel(context.Background())
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+			defer cancel()
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil).WithContext(ctx)
+
+			var handler http.Handler
+			if tt.authenticate {
+				r.Header.Set("Authorization", "Bearer valid")
+				handler = app.Authenticate(nil, blockingStore{}, http.HandlerFunc(next))
+			} else {
+				r = ContextSetPrincipal(r, &TokenPrincipal{Subject: "user-1", Activated: true})
+				handler = app.RequirePermission("movies:write", next)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if called {
+				t.Error("got next called for an abandoned request")
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("got response %q for an abandoned request; want none", w.Body.String())
+			}
+			if buf.Len() != 0 {
+				t.Errorf("got log output %q for an abandoned request; want none", buf.String())
+			}
+		})
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	failing := errors.New("store failed")
+
+	tests := []struct {
+		name        string
+		principal   Principal
+		permissions PermissionStore
+		require     func(app *WebApp, next http.HandlerFunc) http.HandlerFunc
+		wantStatus  int
+	}{
+		{
+			name:       "authenticated without a principal",
+			require:    (*WebApp).RequireAuthenticatedUser,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "authenticated with an inactive principal",
+			principal:  &TokenPrincipal{Subject: "user-1"},
+			require:    (*WebApp).RequireAuthenticatedUser,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "activated without a principal",
+			require:    (*WebApp).RequireActivatedUser,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "activated with an inactive principal",
+			principal:  &TokenPrincipal{Subject: "user-1"},
+			require:    (*WebApp).RequireActivatedUser,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "activated with an activated principal",
+			principal:  &TokenPrincipal{Subject: "user-1", Activated: true},
+			require:    (*WebApp).RequireActivatedUser,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "permission without a principal",
+			require:    requireMoviesWrite,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "permission with an inactive principal",
+			principal:  &TokenPrincipal{Subject: "user-1", Scopes: []string{"movies:write"}},
+			require:    requireMoviesWrite,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "permission missing",
+			principal:  &TokenPrincipal{Subject: "user-1", Activated: true, Scopes: []string{"movies:read"}},
+			require:    requireMoviesWrite,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "permission granted to the principal",
+			principal:  &TokenPrincipal{Subject: "user-1", Activated: true, Scopes: []string{"movies:write"}},
+			require:    requireMoviesWrite,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "permission granted in the store",
+			principal:   &TokenPrincipal{Subject: "user-1", Activated: true},
+			permissions: staticPermissionStore{"user-1": {"movies:write"}},
+			require:     requireMoviesWrite,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "permission missing from the store",
+			principal:   &TokenPrincipal{Subject: "user-1", Activated: true},
+			permissions: staticPermissionStore{"user-1": {"movies:read"}},
+			require:     requireMoviesWrite,
+			wantStatus:  http.StatusForbidden,
+		},
+		{
+			name:        "permission store failing",
+			principal:   &TokenPrincipal{Subject: "user-1", Activated: true},
+			permissions: &countingPermissionStore{err: failing},
+			require:     requireMoviesWrite,
+			wantStatus:  http.StatusInternalServerError,
+		},
+		{
+			name:        "permission store unavailable",
+			principal:   &TokenPrincipal{Subject: "user-1", Activated: true},
+			permissions: &countingPermissionStore{err: access.ErrUnavailable},
+			require:     requireMoviesWrite,
+			wantStatus:  http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.PermissionStore = tt.permissions
+
+			called := false
+			handler := tt.require(app, func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+			if tt.principal != nil {
+				r = ContextSetPrincipal(r, tt.principal)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d; want %d", w.Code, tt.wantStatus)
+			}
+			if called != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("got next called %t; want %t", called, tt.wantStatus == http.StatusOK)
+			}
+		})
+	}
+}
+
+// requireMoviesWrite requires the movies:write permission in the same form as
+// the other Require middlewares.
+func requireMoviesWrite(app *WebApp, next http.HandlerFunc) http.HandlerFunc {
+	return app.RequirePermission("movies:write", next)
+}
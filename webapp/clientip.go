@@ -0,0 +1,183 @@
+package webapp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+)
+
+const clientIPContextKey = contextKey("client_ip")
+
+// ClientIPResolver works out the IP address of the client that made a request.
+// Headers such as X-Forwarded-For are easily spoofed, so only the one header
+// that the proxies set is used, and only when the request came from one of the
+// trusted proxies. Even then, the chain of addresses in the header is walked
+// from the right, skipping trusted proxies, so that addresses added by the
+// client itself are ignored.
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+	header  string
+}
+
+// NewClientIPResolver returns a pointer to a new ClientIPResolver configured
+// from cfg. Any invalid trusted proxies are returned in the error, but the
+// resolver is still usable with the valid ones.
+func NewClientIPResolver(cfg config.Proxy) (*ClientIPResolver, error) {
+	err := config.Validate(&cfg)
+
+	cr := &ClientIPResolver{}
+
+	for _, proxy := range cfg.TrustedProxies {
+		prefix, perr := netip.ParsePrefix(proxy)
+		if perr != nil {
+			addr, aerr := netip.ParseAddr(proxy)
+			if aerr != nil {
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		cr.trusted = append(cr.trusted, prefix.Masked())
+	}
+
+	if cfg.Header != "" {
+		cr.header = http.CanonicalHeaderKey(cfg.Header)
+	}
+
+	return cr, err
+}
+
+// ClientIP returns the IP address of the client that made the request r.
+func (cr *ClientIPResolver) ClientIP(r *http.Request) string {
+	remote := remoteIP(r)
+
+	addr, err := netip.ParseAddr(remote)
+	if err != nil || cr.header == "" || !cr.isTrusted(addr) {
+		return remote
+	}
+
+	var chain []string
+
+	switch cr.header {
+	case "Forwarded":
+		chain = forwardedFor(r.Header.Values(cr.header))
+	case "X-Forwarded-For":
+		for _, value := range r.Header.Values(cr.header) {
+			for _, ip := range strings.Split(value, ",") {
+				chain = append(chain, strings.TrimSpace(ip))
+			}
+		}
+	default:
+		if value := strings.TrimSpace(r.Header.Get(cr.header)); value != "" {
+			chain = []string{value}
+		}
+	}
+
+	if ip, ok := cr.fromChain(chain); ok {
+		return ip
+	}
+
+	return remote
+}
+
+// fromChain returns the rightmost address in chain that is not a trusted
+// proxy. If every address is a trusted proxy, then the leftmost is returned.
+// False is returned if the chain is empty or contains an invalid address.
+func (cr *ClientIPResolver) fromChain(chain []string) (string, bool) {
+	if len(chain) == 0 {
+		return "", false
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(chain[i])
+		if err != nil {
+			return "", false
+		}
+
+		if !cr.isTrusted(addr) || i == 0 {
+			return addr.String(), true
+		}
+	}
+
+	return "", false
+}
+
+func (cr *ClientIPResolver) isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range cr.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor extracts the addresses from the for parameters of the given
+// RFC 7239 Forwarded header values, in order. Ports, brackets around IPv6
+// addresses and quotes are removed; obfuscated identifiers such as "unknown"
+// are kept so that they are rejected as invalid addresses.
+func forwardedFor(values []string) []string {
+	var chain []string
+
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if !found || !strings.EqualFold(key, "for") {
+					continue
+				}
+
+				val = strings.Trim(val, `"`)
+				if strings.HasPrefix(val, "[") {
+					// An IPv6 address, possibly followed by a port.
+					val, _, _ = strings.Cut(strings.TrimPrefix(val, "["), "]")
+				} else if host, _, err := net.SplitHostPort(val); err == nil {
+					val = host
+				}
+
+				chain = append(chain, val)
+			}
+		}
+	}
+
+	return chain
+}
+
+// remoteIP returns the IP address of the direct peer of the request r.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ClientIP is a middleware function that works out the IP address of the
+// client making each request using the trusted proxy configuration in cfg and
+// stores it in the request context. It should be applied before any other
+// middleware that uses the client's IP address.
+func (app *WebApp) ClientIP(cfg config.Proxy, next http.Handler) http.Handler {
+	resolver, err := NewClientIPResolver(cfg)
+	if err != nil {
+		app.Logger.Error(err.Error())
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPContextKey, resolver.ClientIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ClientIPFromRequest returns the client IP address stored in the context of r
+// by the ClientIP middleware. If it is not there, then the address of the
+// direct peer is returned, as no proxy headers can be trusted.
+func ClientIPFromRequest(r *http.Request) string {
+	ip, ok := r.Context().Value(clientIPContextKey).(string)
+	if !ok {
+		return remoteIP(r)
+	}
+	return ip
+}
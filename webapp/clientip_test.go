@@ -0,0 +1,108 @@
+package webapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []string{"10.0.0.0/8"}
+
+	tests := []struct {
+		name    string
+		header  string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "untrusted peer",
+			header:  "X-Forwarded-For",
+			remote:  "203.0.113.7:1234",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "trusted peer",
+			header:  "X-Forwarded-For",
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "client prepends to chain",
+			header:  "X-Forwarded-For",
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "192.0.2.66, 198.51.100.1, 10.0.0.2"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:   "client sends another header",
+			header: "X-Forwarded-For",
+			remote: "10.0.0.1:1234",
+			headers: map[string]string{
+				"X-Real-IP":       "192.0.2.66",
+				"Forwarded":       "for=192.0.2.66",
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:    "no fallback to another header",
+			header:  "X-Forwarded-For",
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Real-IP": "192.0.2.66"},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "forwarded header",
+			header:  "Forwarded",
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"Forwarded": `for="[2001:db8::1]:4711", for=10.0.0.2`},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "x-real-ip header",
+			header:  "x-real-ip",
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "no header configured",
+			header:  "",
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "invalid address in chain",
+			header:  "X-Forwarded-For",
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "unknown"},
+			want:    "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr, err := NewClientIPResolver(config.Proxy{TrustedProxies: trusted, Header: tt.header})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+
+			if got := cr.ClientIP(r); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,26 +1,30 @@
 package webapp
 
-// import (
-// 	"context"
-// 	"net/http"
+import (
+	"context"
+	"net/http"
+)
 
-// 	"m5l.app/go-service-toolkit/data"
-// )
+// contextKey is the type of the keys used to store values in a request's
+// context to avoid collisions with keys from other packages.
+type contextKey string
 
-// type contextKey string
+// typedContextKey is the key used by ContextSet and ContextGet. As each type T
+// gives a distinct key type, values of different types can never collide.
+type typedContextKey[T any] struct{}
 
-// const userContextKey = contextKey("user")
+// ContextSet returns a shallow copy of r with value stored in its context under
+// its type T. Only one value of each type can be stored, so a service should
+// define its own types for values such as its user model rather than storing
+// bare strings or ints.
+func ContextSet[T any](r *http.Request, value T) *http.Request {
+	ctx := context.WithValue(r.Context(), typedContextKey[T]{}, value)
+	return r.WithContext(ctx)
+}
 
-// func (app *WebApp) contextSetUser(r *http.Request, user *data.User) *http.Request {
-// 	ctx := context.WithValue(r.Context(), userContextKey, user)
-// 	return r.WithContext(ctx)
-// }
-
-// func (app *WebApp) contextGetUser(r *http.Request) *data.User {
-// 	user, ok := r.Context().Value(userContextKey).(*data.User)
-// 	if !ok {
-// 		panic("missing user value in request context")
-// 	}
-
-// 	return user
-// }
+// ContextGet returns the value of type T stored in the context of r by
+// ContextSet. The boolean is false if there is not one.
+func ContextGet[T any](r *http.Request) (T, bool) {
+	value, ok := r.Context().Value(typedContextKey[T]{}).(T)
+	return value, ok
+}
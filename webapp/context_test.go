@@ -0,0 +1,57 @@
+package webapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testUserID string
+
+type testAccountID string
+
+func TestContextGet(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = ContextSet(r, testUserID("user-1"))
+	r = ContextSet(r, 42)
+
+	tests := []struct {
+		name   string
+		get    func(r *http.Request) (any, bool)
+		want   any
+		wantOK bool
+	}{
+		{"stored type", func(r *http.Request) (any, bool) { return ContextGet[testUserID](r) }, testUserID("user-1"), true},
+		{"another stored type", func(r *http.Request) (any, bool) { return ContextGet[int](r) }, 42, true},
+		{"same underlying type", func(r *http.Request) (any, bool) { return ContextGet[testAccountID](r) }, testAccountID(""), false},
+		{"underlying type", func(r *http.Request) (any, bool) { return ContextGet[string](r) }, "", false},
+		{"interface type", func(r *http.Request) (any, bool) { return ContextGet[Principal](r) }, Principal(nil), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.get(r)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %v and %t; want %v and %t", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPrincipalFromRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, ok := PrincipalFromRequest(r); ok {
+		t.Error("got a principal from a request without one")
+	}
+
+	// A nil Principal does not count as being authenticated.
+	if _, ok := PrincipalFromRequest(ContextSetPrincipal(r, nil)); ok {
+		t.Error("got a principal from a request with a nil one")
+	}
+
+	principal := &TokenPrincipal{Subject: "user-1"}
+	got, ok := PrincipalFromRequest(ContextSetPrincipal(r, principal))
+	if !ok || got != principal {
+		t.Errorf("got principal %v and %t; want %v and true", got, ok, principal)
+	}
+}
@@ -0,0 +1,145 @@
+package webapp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+)
+
+// EnableCORS is a middleware function that handles CORS (Cross-Origin Resource
+// Sharing) requests to prmit a web browser to make requests to a different
+// origin (domain, scheme or port) to the main we page.
+func (app *WebApp) EnableCORS(cfg config.Cors, next http.Handler) http.Handler {
+	return app.ReloadableCORS(config.NewReloadable(cfg), next)
+}
+
+// ReloadableCORS is the same as EnableCORS, except that the configuration is
+// read from cfg on each request, so the trusted origins can be changed whilst
+// the application is running.
+//
+// Requests from an untrusted origin are passed on to next without any CORS
+// headers, so the browser blocks the response. Preflight requests from a
+// trusted origin are answered directly: if the requested method or any of the
+// requested headers are not allowed, then an HTTP 403 (Forbidden) response is
+// sent.
+func (app *WebApp) ReloadableCORS(cfg *config.Reloadable[config.Cors], next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := cfg.Get()
+
+		preflight := r.Method == http.MethodOptions &&
+			r.Header.Get("Access-Control-Request-Method") != ""
+
+		w.Header().Add("Vary", "Origin")
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin == "" || !corsOriginTrusted(cfg.TrustedOrigins, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if cfg.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if len(cfg.ExposeHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposeHeaders, ", "))
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		method := r.Header.Get("Access-Control-Request-Method")
+		if !corsMethodAllowed(cfg.AllowMethods, method) {
+			app.corsNotAllowedResponse(w, r, "The "+method+" method is not allowed for cross-origin requests")
+			return
+		}
+
+		for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			header = strings.TrimSpace(header)
+			if header != "" && !corsHeaderAllowed(cfg.AllowHeaders, header) {
+				app.corsNotAllowedResponse(w, r, "The "+header+" header is not allowed for cross-origin requests")
+				return
+			}
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowMethods, ", "))
+		if len(cfg.AllowHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowHeaders, ", "))
+		}
+		if cfg.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// corsNotAllowedResponse returns an HTTP 403 (Forbidden) response to a CORS
+// preflight request that asked for a method or header that is not allowed.
+func (app *WebApp) corsNotAllowedResponse(w http.ResponseWriter, r *http.Request, e string) {
+	data := map[string]string{
+		"error": e,
+	}
+	app.FailResponse(w, r, http.StatusForbidden, data)
+}
+
+// corsOriginTrusted checks if origin matches any of the trusted origins, which
+// may be exact origins, a single wildcard subdomain pattern such as
+// https://*.example.com or * to match any origin.
+func corsOriginTrusted(trustedOrigins []string, origin string) bool {
+	origin = strings.ToLower(origin)
+
+	for _, trusted := range trustedOrigins {
+		trusted = strings.ToLower(trusted)
+
+		if trusted == "*" || trusted == origin {
+			return true
+		}
+
+		prefix, suffix, found := strings.Cut(trusted, "*")
+		if !found || len(origin) <= len(prefix)+len(suffix) {
+			continue
+		}
+
+		if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			// The wildcard may only match subdomains and not, for example, a
+			// port or path.
+			wildcard := origin[len(prefix) : len(origin)-len(suffix)]
+			if !strings.ContainsAny(wildcard, "/:@") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// corsMethodAllowed checks if method is one of the allowed methods.
+func corsMethodAllowed(allowMethods []string, method string) bool {
+	for _, allowed := range allowMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsHeaderAllowed checks if header is one of the allowed headers. Header
+// names are case-insensitive and * allows any header.
+func corsHeaderAllowed(allowHeaders []string, header string) bool {
+	for _, allowed := range allowHeaders {
+		if allowed == "*" || strings.EqualFold(allowed, header) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package webapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+)
+
+func TestCorsOriginTrusted(t *testing.T) {
+	trusted := []string{"https://www.example.com", "https://*.example.org"}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://www.example.com", true},
+		{"HTTPS://WWW.EXAMPLE.COM", true},
+		{"http://www.example.com", false},
+		{"https://www.example.com:8443", false},
+		{"https://evil.com", false},
+		{"https://api.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://.example.org", false},
+		{"https://evil.com/.example.org", false},
+		{"https://evil.com:1.example.org", false},
+		{"https://user@evil.com.example.org", false},
+		{"https://api.example.org.evil.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			if got := corsOriginTrusted(trusted, tt.origin); got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+
+	if !corsOriginTrusted([]string{"*"}, "https://anything.example.net") {
+		t.Error("got false for * trusted origin; want true")
+	}
+}
+
+func TestEnableCORS(t *testing.T) {
+	cfg := config.Cors{
+		AllowMethods:     []string{http.MethodGet, http.MethodPut},
+		AllowHeaders:     []string{"Authorization", "Content-Type"},
+		ExposeHeaders:    []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+		TrustedOrigins:   []string{"https://www.example.com"},
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		reqMethod  string
+		reqHeaders string
+		wantStatus int
+		wantHeader map[string]string
+	}{
+		{
+			name:       "simple request from trusted origin",
+			method:     http.MethodGet,
+			origin:     "https://www.example.com",
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "https://www.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-ID",
+			},
+		},
+		{
+			name:       "simple request from untrusted origin",
+			method:     http.MethodGet,
+			origin:     "https://evil.com",
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:       "preflight request",
+			method:     http.MethodOptions,
+			origin:     "https://www.example.com",
+			reqMethod:  http.MethodPut,
+			reqHeaders: "content-type, authorization",
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "https://www.example.com",
+				"Access-Control-Allow-Methods": "GET, PUT",
+				"Access-Control-Allow-Headers": "Authorization, Content-Type",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:       "preflight request for disallowed method",
+			method:     http.MethodOptions,
+			origin:     "https://www.example.com",
+			reqMethod:  http.MethodDelete,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "preflight request for disallowed header",
+			method:     http.MethodOptions,
+			origin:     "https://www.example.com",
+			reqMethod:  http.MethodPut,
+			reqHeaders: "X-Custom",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			handler := app.EnableCORS(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			r := httptest.NewRequest(tt.method, "/v1/movies", nil)
+			r.Header.Set("Origin", tt.origin)
+			if tt.reqMethod != "" {
+				r.Header.Set("Access-Control-Request-Method", tt.reqMethod)
+			}
+			if tt.reqHeaders != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.reqHeaders)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d; want %d", w.Code, tt.wantStatus)
+			}
+			for key, want := range tt.wantHeader {
+				if got := w.Header().Get(key); got != want {
+					t.Errorf("got %s header %q; want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -32,10 +32,12 @@ func NewClientErrorResponse(err, details, action string) clientErrorResponse {
 }
 
 // logError is a helper function for logging errors along with details of the
-// request that caused it.
+// request that caused it. It uses the request-scoped logger from
+// RequestLogger(), so the request ID, route and trace ID are included too.
 func (app *WebApp) logError(r *http.Request, err error) {
 	trace := debug.Stack()
-	app.Logger.Error(err.Error(),
+	app.RequestLogger(r).Error(err.Error(),
+		"client_ip", ClientIPFromRequest(r),
 		"request_method", r.Method,
 		"request_url", r.URL.String(),
 		"stack_trace", string(trace),
@@ -58,12 +60,30 @@ func (app *WebApp) errorResponse(w http.ResponseWriter, r *http.Request,
 
 // ServerErrorResponse sends a generic error message to the client with an HTTP
 // 500 (Internal Server Error) error code so as not to disclose to much
-// information to the client. Details of the error will be logged locally.
+// information to the client. Details of the error will be logged locally. If
+// the request has an ID, then it is included in the response so that the client
+// can quote it when reporting the problem.
 func (app *WebApp) ServerErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
 
+	var data any
+	if id := RequestIDFromRequest(r); id != "" {
+		data = map[string]string{"request_id": id}
+	}
+
 	msg := "The server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, msg, nil, nil)
+	app.errorResponse(w, r, http.StatusInternalServerError, msg, nil, data)
+}
+
+// ServiceUnavailableResponse sends an HTTP 503 (Service Unavailable) error
+// response for when a service that the request depends on, such as an auth
+// service, is temporarily unavailable. The error is logged locally as a
+// warning, as it is expected to clear up by itself.
+func (app *WebApp) ServiceUnavailableResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.RequestLogger(r).Warn(err.Error(), "client_ip", ClientIPFromRequest(r))
+
+	msg := "The server is temporarily unable to process your request, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, msg, nil, nil)
 }
 
 // Client-side error response functions.
@@ -133,7 +153,7 @@ func (app *WebApp) EditConflictResponse(w http.ResponseWriter, r *http.Request)
 func (app *WebApp) RateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	data := map[string]string{
 		"error":   "Rate limit exceeded",
-		"details": "Large numbers of requests from the same IP address are limited over time",
+		"details": "Large numbers of requests from the same client are limited over time",
 		"action":  "Wait a while and then try again, or send fewer requests",
 	}
 	app.FailResponse(w, r, http.StatusTooManyRequests, data)
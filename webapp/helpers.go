@@ -1,6 +1,7 @@
 package webapp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -60,7 +61,22 @@ func (app *WebApp) ReadInt(qs url.Values, key string, defaultValue int,
 	return i
 }
 
+// Background runs fn in a new goroutine that the server waits for when it is
+// shutting down. Any panic in fn is recovered and logged.
 func (app *WebApp) Background(fn func()) {
+	app.BackgroundContext(context.Background(), func(context.Context) { fn() })
+}
+
+// BackgroundContext is the same as Background, except that fn is passed a
+// context carrying the values from ctx, which is usually the context of the
+// request that started the task, along with its logger. The new context is not
+// cancelled when the request finishes, so that the task can outlive it. Any
+// panic in fn is logged with the logger from ctx, or the WebApp's Logger if it
+// does not have one.
+func (app *WebApp) BackgroundContext(ctx context.Context, fn func(ctx context.Context)) {
+	logger := loggerFromContext(ctx, app.Logger)
+	bgCtx := ContextWithLogger(context.WithoutCancel(ctx), logger)
+
 	app.Wg.Add(1)
 	go func() {
 		defer app.Wg.Done()
@@ -70,10 +86,10 @@ func (app *WebApp) Background(fn func()) {
 			if err != nil {
 				// As recover() returns an any type, create an error out of it.
 				e := fmt.Errorf("%s", err)
-				app.Logger.Error(e.Error(), nil)
+				logger.Error(e.Error())
 			}
 		}()
 
-		fn()
+		fn(bgCtx)
 	}()
 }
@@ -0,0 +1,358 @@
+package webapp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/m5lapp/go-service-toolkit/access"
+	"github.com/m5lapp/go-service-toolkit/config"
+	"golang.org/x/sync/singleflight"
+)
+
+// minJWKSFetchInterval is the shortest time between fetches of the JWKS when a
+// token is signed with a key that is not in it, to stop a flood of tokens with
+// made up key IDs from hammering the JWKS endpoint.
+const minJWKSFetchInterval = time.Minute
+
+// TokenPrincipal is the Principal for a request authenticated with a JWT, or
+// with an opaque token by a TokenStore that has no type of its own to return.
+// Its permissions are the scopes granted to the token.
+type TokenPrincipal struct {
+	Subject   string
+	Issuer    string
+	Scopes    []string
+	Activated bool
+	// Claims holds all of the claims in a JWT, including those above.
+	Claims map[string]any
+}
+
+// ID implements the Principal interface.
+func (p *TokenPrincipal) ID() string {
+	return p.Subject
+}
+
+// IsActivated implements the Principal interface.
+func (p *TokenPrincipal) IsActivated() bool {
+	return p.Activated
+}
+
+// HasPermission implements the Principal interface.
+func (p *TokenPrincipal) HasPermission(code string) bool {
+	return slices.Contains(p.Scopes, code)
+}
+
+// JWTVerifier validates JSON Web Tokens signed with the algorithms and keys in
+// its config.JWT. The JWKS, if there is one, is loaded when the JWTVerifier is
+// created and reloaded when it is older than the configured refresh interval,
+// or when a token is signed with a key ID that is not in it, for example
+// because the issuer has rotated its keys.
+type JWTVerifier struct {
+	cfg    config.JWT
+	client *http.Client
+
+	// refreshGroup makes sure that only one refresh of the JWKS is in flight
+	// at a time.
+	refreshGroup singleflight.Group
+
+	mu        sync.Mutex
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+// NewJWTVerifier returns a pointer to a new JWTVerifier configured from cfg.
+// An error is returned if the JWKS cannot be loaded.
+func NewJWTVerifier(ctx context.Context, cfg config.JWT) (*JWTVerifier, error) {
+	jv := &JWTVerifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if cfg.JWKSFile != "" || cfg.JWKSURL != "" {
+		err := jv.refreshKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return jv, nil
+}
+
+// Verify checks that token is a validly signed JWT whose claims satisfy the
+// configured issuer, audience and time constraints and returns its Principal.
+// If the JWKS needed to check the signature cannot be loaded, then the error
+// returned wraps access.ErrUnavailable, and if ctx is done whilst waiting for
+// it, then ctx's error is returned. Any other error wraps
+// access.ErrInvalidToken.
+func (jv *JWTVerifier) Verify(ctx context.Context, token string) (*TokenPrincipal, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(jv.cfg.Algorithms),
+		jwt.WithLeeway(jv.cfg.Leeway),
+		jwt.WithExpirationRequired(),
+	}
+	if jv.cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(jv.cfg.Issuer))
+	}
+	if jv.cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(jv.cfg.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		return jv.key(ctx, t)
+	}, opts...)
+	if err != nil {
+		// Neither of these say anything about whether the token is valid.
+		if errors.Is(err, access.ErrUnavailable) || ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", access.ErrInvalidToken, err)
+	}
+
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return nil, fmt.Errorf("%w: token has no subject", access.ErrInvalidToken)
+	}
+	iss, _ := claims.GetIssuer()
+
+	// Tokens are normally only issued to activated accounts, so they are
+	// treated as such unless they have an activated claim saying otherwise.
+	activated, ok := claims["activated"].(bool)
+	if !ok {
+		activated = true
+	}
+
+	return &TokenPrincipal{
+		Subject:   sub,
+		Issuer:    iss,
+		Scopes:    scopesFromClaims(claims),
+		Activated: activated,
+		Claims:    claims,
+	}, nil
+}
+
+// key returns the key to verify the signature of t with. The JWKS is refreshed
+// outside of jv.mu so that verifications with cached keys are not held up by a
+// slow JWKS endpoint. If the key is known but the JWKS is stale, then the key
+// is used whilst the JWKS is refreshed in the background.
+func (jv *JWTVerifier) key(ctx context.Context, t *jwt.Token) (any, error) {
+	if t.Method.Alg() == config.JWTAlgorithmHS256 {
+		if !jv.cfg.HMACSecret.IsSet() {
+			return nil, errors.New("no HMAC secret configured")
+		}
+		return []byte(jv.cfg.HMACSecret.Value()), nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+
+	jv.mu.Lock()
+	key, found := jv.lookupKey(kid)
+	age := time.Since(jv.fetchedAt)
+	jv.mu.Unlock()
+
+	if found && age <= jv.cfg.JWKSRefresh {
+		return key, nil
+	}
+
+	if age < minJWKSFetchInterval {
+		if !found {
+			return nil, fmt.Errorf("unknown key ID %q", kid)
+		}
+		return key, nil
+	}
+
+	// The refresh is shared by every request waiting for it, so it must not be
+	// cancelled if the request that started it goes away.
+	result := jv.refreshGroup.DoChan("jwks", func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jv.client.Timeout)
+		defer cancel()
+
+		return nil, jv.refreshKeys(ctx)
+	})
+
+	if found {
+		return key, nil
+	}
+
+	select {
+	case res := <-result:
+		if res.Err != nil {
+			return nil, fmt.Errorf("%w: %w", res.Err, access.ErrUnavailable)
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	jv.mu.Lock()
+	key, found = jv.lookupKey(kid)
+	jv.mu.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+
+	return key, nil
+}
+
+// lookupKey returns the key with the given ID. A token without a key ID can
+// only be verified if there is exactly one key. jv.mu must already be held.
+func (jv *JWTVerifier) lookupKey(kid string) (any, bool) {
+	if kid == "" && len(jv.keys) == 1 {
+		for _, key := range jv.keys {
+			return key, true
+		}
+	}
+
+	key, found := jv.keys[kid]
+	return key, found
+}
+
+// refreshKeys reloads the JWKS from its file or URL. jv.mu is only held
+// whilst the keys are swapped, not whilst they are loaded.
+func (jv *JWTVerifier) refreshKeys(ctx context.Context) error {
+	// Record the attempt even if it fails so that a broken JWKS endpoint is not
+	// retried on every request.
+	jv.mu.Lock()
+	jv.fetchedAt = time.Now()
+	jv.mu.Unlock()
+
+	var data []byte
+	var err error
+
+	if jv.cfg.JWKSFile != "" {
+		data, err = os.ReadFile(jv.cfg.JWKSFile)
+	} else {
+		data, err = jv.fetchJWKS(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to load JWKS: %w", err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("unable to parse JWKS: %w", err)
+	}
+
+	jv.mu.Lock()
+	jv.keys = keys
+	jv.mu.Unlock()
+
+	return nil
+}
+
+// fetchJWKS downloads the JWKS from the configured URL.
+func (jv *JWTVerifier) fetchJWKS(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jv.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := jv.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching %s: %s", jv.cfg.JWKSURL, resp.Status)
+	}
+
+	var raw json.RawMessage
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1_048_576)).Decode(&raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// jwk is a single JSON Web Key. Only the fields needed for RSA and Ed25519
+// public keys are decoded.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+}
+
+// parseJWKS parses a JSON Web Key Set and returns its RSA and Ed25519 public
+// keys by key ID. Keys of other types and encryption keys are skipped.
+func parseJWKS(data []byte) (map[string]any, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]any)
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		switch {
+		case k.Kty == "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: invalid modulus: %w", k.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: invalid exponent: %w", k.Kid, err)
+			}
+			keys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+
+		case k.Kty == "OKP" && k.Crv == "Ed25519":
+			x, err := base64.RawURLEncoding.DecodeString(k.X)
+			if err != nil || len(x) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("key %q: invalid Ed25519 public key", k.Kid)
+			}
+			keys[k.Kid] = ed25519.PublicKey(x)
+		}
+	}
+
+	return keys, nil
+}
+
+// scopesFromClaims returns the scopes granted by a JWT, which may be given as
+// a space separated string in the scope claim or as a list in the scp claim.
+func scopesFromClaims(claims jwt.MapClaims) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+
+	var scopes []string
+	if scp, ok := claims["scp"].([]any); ok {
+		for _, s := range scp {
+			if str, ok := s.(string); ok {
+				scopes = append(scopes, str)
+			}
+		}
+	}
+
+	return scopes
+}
@@ -0,0 +1,289 @@
+package webapp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/m5lapp/go-service-toolkit/access"
+	"github.com/m5lapp/go-service-toolkit/config"
+)
+
+func TestJWTVerifierHS256(t *testing.T) {
+	secret, err := config.ParseSecret("test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.JWT{
+		Algorithms: []string{config.JWTAlgorithmHS256},
+		HMACSecret: secret,
+		Issuer:     "https://auth.example.com",
+		Audience:   "books-api",
+	}
+
+	jv, err := NewJWTVerifier(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":   "user-1",
+			"iss":   "https://auth.example.com",
+			"aud":   "books-api",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "books:read books:write",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		claims  func() jwt.MapClaims
+		key     string
+		wantErr bool
+	}{
+		{"valid", valid, "test-secret", false},
+		{"wrong key", valid, "other-secret", true},
+		{"expired", func() jwt.MapClaims {
+			c := valid()
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+			return c
+		}, "test-secret", true},
+		{"no expiry", func() jwt.MapClaims {
+			c := valid()
+			delete(c, "exp")
+			return c
+		}, "test-secret", true},
+		{"wrong issuer", func() jwt.MapClaims {
+			c := valid()
+			c["iss"] = "https://evil.example.com"
+			return c
+		}, "test-secret", true},
+		{"wrong audience", func() jwt.MapClaims {
+			c := valid()
+			c["aud"] = "other-api"
+			return c
+		}, "test-secret", true},
+		{"no subject", func() jwt.MapClaims {
+			c := valid()
+			delete(c, "sub")
+			return c
+		}, "test-secret", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims()).SignedString([]byte(tt.key))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			principal, err := jv.Verify(context.Background(), token)
+			if tt.wantErr {
+				if !errors.Is(err, access.ErrInvalidToken) {
+					t.Errorf("got error %v; want one wrapping access.ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if principal.ID() != "user-1" {
+				t.Errorf("got subject %q; want %q", principal.ID(), "user-1")
+			}
+			if !principal.HasPermission("books:write") {
+				t.Errorf("got scopes %v; want books:write to be included", principal.Scopes)
+			}
+		})
+	}
+}
+
+// jwksServer serves a JWKS of Ed25519 keys. Whilst it is blocked, requests for
+// the JWKS wait until it is unblocked.
+type jwksServer struct {
+	mu      sync.Mutex
+	keys    map[string]ed25519.PublicKey
+	blocked chan struct{}
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	blocked := s.blocked
+	s.mu.Unlock()
+
+	if blocked != nil {
+		<-blocked
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jwks struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for kid, key := range s.keys {
+		jwks.Keys = append(jwks.Keys, map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"kid": kid,
+			"x":   base64.RawURLEncoding.EncodeToString(key),
+		})
+	}
+
+	json.NewEncoder(w).Encode(jwks)
+}
+
+func (s *jwksServer) setKey(kid string, key ed25519.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[kid] = key
+}
+
+func (s *jwksServer) block() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blocked = make(chan struct{})
+}
+
+func (s *jwksServer) unblock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	close(s.blocked)
+	s.blocked = nil
+}
+
+func signEdDSA(t *testing.T, kid string, key ed25519.PrivateKey) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestJWTVerifierJWKSRefresh(t *testing.T) {
+	pubA, privA, _ := ed25519.GenerateKey(rand.Reader)
+	pubB, privB, _ := ed25519.GenerateKey(rand.Reader)
+
+	jwks := &jwksServer{keys: map[string]ed25519.PublicKey{"a": pubA}}
+	srv := httptest.NewServer(jwks)
+	defer srv.Close()
+
+	cfg := config.JWT{
+		Algorithms:  []string{config.JWTAlgorithmEdDSA},
+		JWKSURL:     srv.URL,
+		JWKSRefresh: time.Hour,
+	}
+
+	jv, err := NewJWTVerifier(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Make the JWKS stale and the endpoint slow, then rotate in a new key.
+	jv.mu.Lock()
+	jv.fetchedAt = time.Now().Add(-2 * time.Hour)
+	jv.mu.Unlock()
+	jwks.block()
+	jwks.setKey("b", pubB)
+
+	// A known key is used straight away whilst the refresh is in flight.
+	done := make(chan error, 1)
+	go func() {
+		_, err := jv.Verify(context.Background(), signEdDSA(t, "a", privA))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("verifying with a cached key: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("verifying with a cached key was blocked by the JWKS refresh")
+	}
+
+	// A request that gives up waiting for the new key does not cancel the
+	// refresh for everyone else.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = jv.Verify(ctx, signEdDSA(t, "b", privB))
+	if !errors.Is(err, context.Canceled) || errors.Is(err, access.ErrInvalidToken) {
+		t.Fatalf("got error %v verifying with a cancelled context; want context.Canceled", err)
+	}
+
+	jwks.unblock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		jv.mu.Lock()
+		_, found := jv.keys["b"]
+		jv.mu.Unlock()
+
+		if found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the JWKS refresh did not complete")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, err = jv.Verify(context.Background(), signEdDSA(t, "b", privB))
+	if err != nil {
+		t.Fatalf("verifying with the rotated key: %v", err)
+	}
+}
+
+func TestJWTVerifierJWKSUnavailable(t *testing.T) {
+	pubA, _, _ := ed25519.GenerateKey(rand.Reader)
+	_, privB, _ := ed25519.GenerateKey(rand.Reader)
+
+	srv := httptest.NewServer(&jwksServer{keys: map[string]ed25519.PublicKey{"a": pubA}})
+
+	cfg := config.JWT{
+		Algorithms:  []string{config.JWTAlgorithmEdDSA},
+		JWKSURL:     srv.URL,
+		JWKSRefresh: time.Hour,
+	}
+
+	jv, err := NewJWTVerifier(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Take the JWKS endpoint down and make the JWKS stale, so that a token
+	// with a new key ID has to wait for a refresh that fails.
+	srv.Close()
+	jv.mu.Lock()
+	jv.fetchedAt = time.Now().Add(-2 * time.Hour)
+	jv.mu.Unlock()
+
+	_, err = jv.Verify(context.Background(), signEdDSA(t, "b", privB))
+	if !errors.Is(err, access.ErrUnavailable) || errors.Is(err, access.ErrInvalidToken) {
+		t.Fatalf("got error %v; want one wrapping access.ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,194 @@
+package webapp
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+	"github.com/m5lapp/go-service-toolkit/requestid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const loggerContextKey = contextKey("logger")
+
+// NewLogger returns a logger that writes to w in the LogFormat given by cfg,
+// along with the LevelVar that controls its minimum level, which is initialised
+// from cfg.LogLevel. An empty or invalid level leaves it at the default of
+// info, and anything other than config.LogFormatText results in JSON.
+func NewLogger(w io.Writer, cfg config.Server) (*slog.Logger, *slog.LevelVar) {
+	level := &slog.LevelVar{}
+	level.UnmarshalText([]byte(cfg.LogLevel))
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if cfg.LogFormat == config.LogFormatText {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.New(handler), level
+}
+
+// levelHandler is a slog.Handler that passes the records at or above level on
+// to handler, as long as handler is enabled for them too.
+type levelHandler struct {
+	level   *slog.LevelVar
+	handler slog.Handler
+}
+
+// Enabled implements the slog.Handler interface.
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.handler.Enabled(ctx, level)
+}
+
+// Handle implements the slog.Handler interface.
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+// WithAttrs implements the slog.Handler interface.
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{level: h.level, handler: h.handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements the slog.Handler interface.
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{level: h.level, handler: h.handler.WithGroup(name)}
+}
+
+// loggerHolder holds a request-scoped logger. A pointer to one is stored in the
+// request context so that attributes added by AddLogAttrs() deep inside the
+// middleware chain also reach the middlewares further out, such as AccessLog.
+type loggerHolder struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+// withLoggerHolder returns r with a loggerHolder in its context, along with
+// the holder. If r already has one, then r is returned unchanged.
+func withLoggerHolder(r *http.Request) (*http.Request, *loggerHolder) {
+	holder, ok := r.Context().Value(loggerContextKey).(*loggerHolder)
+	if ok {
+		return r, holder
+	}
+
+	holder = &loggerHolder{}
+	ctx := context.WithValue(r.Context(), loggerContextKey, holder)
+
+	return r.WithContext(ctx), holder
+}
+
+// ContextWithLogger returns a copy of ctx that carries logger.
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey, &loggerHolder{logger: logger})
+}
+
+// LoggerFromContext returns the logger carried by ctx with the route added to
+// it if one has been matched, or slog.Default() if ctx does not carry one.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	return loggerFromContext(ctx, slog.Default())
+}
+
+// ContextLogger is a middleware function that stores a request-scoped logger
+// in the request context. It is derived from the WebApp's Logger with the
+// request ID, trace ID and span ID added to it, so it should be applied inside
+// the RequestID and Trace middlewares. The route is added when the logger is
+// retrieved with RequestLogger() or LoggerFromContext(), once the Router has
+// matched it. If an outer middleware, such as AccessLog, has already made room
+// for the logger in the context, then it is shared with that middleware.
+func (app *WebApp) ContextLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, holder := withLoggerHolder(r)
+
+		holder.mu.Lock()
+		if holder.logger == nil {
+			holder.logger = app.Logger.With(requestLogAttrs(r.Context())...)
+		}
+		holder.mu.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequestLogger returns the request-scoped logger stored in the context of r
+// by the ContextLogger middleware, with the route added to it if one has been
+// matched. If there is not one, then the WebApp's Logger is returned with the
+// same attributes added to it.
+func (app *WebApp) RequestLogger(r *http.Request) *slog.Logger {
+	return loggerFromContext(r.Context(), app.Logger)
+}
+
+// AddLogAttrs adds the given attributes to the request-scoped logger of r, in
+// the same form as the args of slog.Logger.With(). It is intended for
+// middlewares that learn more about the request, such as who the user is, so
+// that later log records include it. The logger is shared with the middlewares
+// that r has already passed through, so the AccessLog record includes the
+// attributes too. It returns r, or a shallow copy of r if it did not have a
+// request-scoped logger yet.
+func (app *WebApp) AddLogAttrs(r *http.Request, args ...any) *http.Request {
+	r, holder := withLoggerHolder(r)
+
+	holder.mu.Lock()
+	defer holder.mu.Unlock()
+
+	logger := holder.logger
+	if logger == nil {
+		logger = app.Logger.With(requestLogAttrs(r.Context())...)
+	}
+	holder.logger = logger.With(args...)
+
+	return r
+}
+
+// loggerFromContext returns the logger from storedLogger() with the route
+// pattern added to it if one has been matched.
+func loggerFromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	logger := storedLogger(ctx, fallback)
+
+	// The route is only known once the Router has matched the request, which
+	// is usually after the logger was stored, so it is added here instead.
+	if route, ok := ctx.Value(routeContextKey).(*routeHolder); ok && route.pattern != "" {
+		logger = logger.With(slog.String("route", route.pattern))
+	}
+
+	return logger
+}
+
+// storedLogger returns the logger carried by ctx, or fallback with the
+// attributes from requestLogAttrs() added if it does not carry one.
+func storedLogger(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if holder, ok := ctx.Value(loggerContextKey).(*loggerHolder); ok {
+		holder.mu.Lock()
+		logger := holder.logger
+		holder.mu.Unlock()
+
+		if logger != nil {
+			return logger
+		}
+	}
+
+	return fallback.With(requestLogAttrs(ctx)...)
+}
+
+// requestLogAttrs returns the attributes that identify the request with the
+// context ctx in log records. Values that ctx does not have are left out.
+func requestLogAttrs(ctx context.Context) []any {
+	var attrs []any
+
+	if id := requestid.FromContext(ctx); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs,
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+
+	return attrs
+}
@@ -0,0 +1,171 @@
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+	"github.com/m5lapp/go-service-toolkit/requestid"
+)
+
+// logRecords decodes the JSON log records written to buf.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var record map[string]any
+		err := json.Unmarshal([]byte(line), &record)
+		if err != nil {
+			t.Fatalf("decoding log record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       config.Server
+		wantLevel slog.Level
+		wantJSON  bool
+	}{
+		{"text", config.Server{LogLevel: "debug", LogFormat: config.LogFormatText}, slog.LevelDebug, false},
+		{"JSON", config.Server{LogLevel: "warn", LogFormat: config.LogFormatJSON}, slog.LevelWarn, true},
+		{"defaults", config.Server{}, slog.LevelInfo, true},
+		{"invalid level", config.Server{LogLevel: "loud"}, slog.LevelInfo, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, level := NewLogger(&buf, tt.cfg)
+
+			if level.Level() != tt.wantLevel {
+				t.Errorf("got level %v; want %v", level.Level(), tt.wantLevel)
+			}
+
+			logger.Log(context.Background(), tt.wantLevel, "logged")
+			logger.Log(context.Background(), tt.wantLevel-1, "dropped")
+
+			out := buf.String()
+			if strings.Contains(out, "dropped") || !strings.Contains(out, "logged") {
+				t.Errorf("got output %q; want only the record at the level", out)
+			}
+			if got := strings.HasPrefix(out, "{"); got != tt.wantJSON {
+				t.Errorf("got JSON output %t; want %t", got, tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestRequestLoggerAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	app := newTestApp(t)
+	app.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	app.HandlerFunc(http.MethodGet, "/v1/movies/:id", func(w http.ResponseWriter, r *http.Request) {
+		app.RequestLogger(r).Info("handled")
+	})
+
+	// identify stands in for a middleware that learns who the user is.
+	identify := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			app.RequestLogger(r).Info("identifying")
+			r = app.AddLogAttrs(r, "user_id", "user-1")
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	handler := app.RequestID(app.ContextLogger(identify(app.Router)))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/movies/42", nil)
+	req.Header.Set(requestid.Header, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	records := logRecords(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("got %d log records; want 2", len(records))
+	}
+
+	before, after := records[0], records[1]
+	if before["request_id"] != "req-1" || before["user_id"] != nil {
+		t.Errorf("got record %v before AddLogAttrs; want the request ID and no user ID", before)
+	}
+	if after["request_id"] != "req-1" || after["user_id"] != "user-1" || after["route"] != "/v1/movies/:id" {
+		t.Errorf("got record %v after AddLogAttrs; want the request ID, user ID and route", after)
+	}
+}
+
+func TestAddLogAttrsWithoutContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	app := newTestApp(t)
+	app.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(requestid.NewContext(r.Context(), "req-1"))
+	r = app.AddLogAttrs(r, "user_id", "user-1")
+	app.RequestLogger(r).Info("handled")
+
+	records := logRecords(t, &buf)
+	if len(records) != 1 || records[0]["request_id"] != "req-1" || records[0]["user_id"] != "user-1" {
+		t.Errorf("got records %v; want one with the request ID and user ID", records)
+	}
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	if got := LoggerFromContext(context.Background()); got != slog.Default() {
+		t.Error("got a logger other than slog.Default() from a context without one")
+	}
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	if got := LoggerFromContext(ContextWithLogger(context.Background(), logger)); got != logger {
+		t.Error("got a logger other than the one stored in the context")
+	}
+}
+
+func TestBackgroundContext(t *testing.T) {
+	var buf bytes.Buffer
+	app := newTestApp(t)
+	app.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(requestid.NewContext(r.Context(), "req-1"))
+	r = app.AddLogAttrs(r, "user_id", "user-1")
+
+	ctx, cancel := context.WithCancel(r.Context())
+	cancel()
+
+	var taskErr error
+	var taskRequestID string
+	app.BackgroundContext(ctx, func(ctx context.Context) {
+		taskErr = ctx.Err()
+		taskRequestID = requestid.FromContext(ctx)
+		LoggerFromContext(ctx).Info("background task")
+	})
+	app.Wg.Wait()
+
+	if taskErr != nil {
+		t.Errorf("got context error %v in the background task; want none", taskErr)
+	}
+	if taskRequestID != "req-1" {
+		t.Errorf("got request ID %q in the background task; want %q", taskRequestID, "req-1")
+	}
+
+	records := logRecords(t, &buf)
+	if len(records) != 1 || records[0]["request_id"] != "req-1" || records[0]["user_id"] != "user-1" {
+		t.Errorf("got records %v; want one with the request ID and user ID", records)
+	}
+}
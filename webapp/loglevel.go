@@ -0,0 +1,194 @@
+package webapp
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/serialisation/jsonz"
+	"github.com/m5lapp/go-service-toolkit/validator"
+)
+
+// maxLogLevelDuration is the longest that the log level can be changed for via
+// the /admin/log-level endpoint before it is reverted.
+const maxLogLevelDuration = 24 * time.Hour
+
+// AdminTokenHeader is the request header that RequireAdminToken() expects the
+// admin token in. A dedicated header is used rather than Authorization so that
+// the admin token is not mistaken for a bearer token by Authenticate().
+const AdminTokenHeader = "X-Admin-Token"
+
+// logLevelReverter reverts a temporary change to the WebApp's LogLevel.
+type logLevelReverter struct {
+	mu       sync.Mutex
+	timer    *time.Timer
+	revertTo slog.Level
+	revertAt time.Time
+}
+
+// SetLogLevel parses level and sets the WebApp's LogLevel to it, cancelling
+// any pending revert from SetLogLevelFor(). The LogLevel controls the Logger
+// given to New(), as well as any created with it by NewLogger().
+func (app *WebApp) SetLogLevel(level string) error {
+	return app.SetLogLevelFor(level, 0)
+}
+
+// SetLogLevelFor is the same as SetLogLevel, except that the LogLevel is
+// reverted after duration d if it is greater than zero. If the level is changed
+// again before then, the revert is rescheduled, but the level is still reverted
+// to the one that was in use before the first temporary change.
+func (app *WebApp) SetLogLevelFor(level string, d time.Duration) error {
+	var newLevel slog.Level
+	err := newLevel.UnmarshalText([]byte(level))
+	if err != nil {
+		return err
+	}
+
+	lr := app.logLevelReverter
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	pending := lr.timer != nil
+	if pending {
+		lr.timer.Stop()
+		lr.timer = nil
+		lr.revertAt = time.Time{}
+	}
+
+	if d > 0 {
+		if !pending {
+			lr.revertTo = app.LogLevel.Level()
+		}
+		lr.revertAt = time.Now().Add(d)
+
+		var timer *time.Timer
+		timer = time.AfterFunc(d, func() {
+			lr.mu.Lock()
+			defer lr.mu.Unlock()
+
+			// The timer may have been replaced whilst waiting for the lock.
+			if lr.timer != timer {
+				return
+			}
+
+			app.LogLevel.Set(lr.revertTo)
+			lr.timer = nil
+			lr.revertAt = time.Time{}
+			app.Logger.Info("Log level reverted", "level", lr.revertTo.String())
+		})
+		lr.timer = timer
+	}
+
+	app.LogLevel.Set(newLevel)
+
+	return nil
+}
+
+// logLevelStatus returns the current log level along with the time it will be
+// reverted and the level it will be reverted to, if a revert is pending.
+func (app *WebApp) logLevelStatus() jsonz.Envelope {
+	lr := app.logLevelReverter
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	data := jsonz.Envelope{"level": app.LogLevel.Level().String()}
+	if lr.timer != nil {
+		data["revert_at"] = lr.revertAt
+		data["revert_to"] = lr.revertTo.String()
+	}
+
+	return data
+}
+
+// RequireAdminToken is a middleware function that only allows requests with
+// the ServerConfig's AdminToken in an X-Admin-Token header through to next.
+// If no AdminToken is configured, then the route is treated as if it does not
+// exist.
+func (app *WebApp) RequireAdminToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminToken := app.ServerConfig.AdminToken
+		if !adminToken.IsSet() {
+			app.NotFoundResponse(w, r)
+			return
+		}
+
+		token := r.Header.Get(AdminTokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken.Value())) != 1 {
+			app.invalidAdminTokenResponse(w, r)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// invalidAdminTokenResponse returns an HTTP 401 (Unauthorized) response to a
+// request with a missing or wrong admin token. Unlike
+// InvalidAuthenticationTokenResponse, it does not send a WWW-Authenticate
+// header, as the admin token is not a bearer token.
+func (app *WebApp) invalidAdminTokenResponse(w http.ResponseWriter, r *http.Request) {
+	data := map[string]string{
+		"error":  "Invalid or missing admin token",
+		"action": "Send the admin token in the " + AdminTokenHeader + " header",
+	}
+	app.FailResponse(w, r, http.StatusUnauthorized, data)
+}
+
+// showLogLevelHandler responds with the current log level and details of any
+// pending revert.
+func (app *WebApp) showLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	err := jsonz.WriteJSendSuccess(w, http.StatusOK, nil, app.logLevelStatus())
+	if err != nil {
+		app.ServerErrorResponse(w, r, err)
+	}
+}
+
+// updateLogLevelHandler changes the log level to the one in the request body.
+// If the body also has a duration, such as 15m, then the level is reverted
+// after that long.
+func (app *WebApp) updateLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Level    string `json:"level"`
+		Duration string `json:"duration"`
+	}
+
+	err := jsonz.ReadJSON(w, r, &input)
+	if err != nil {
+		app.BadRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	var level slog.Level
+	v.Check(level.UnmarshalText([]byte(input.Level)) == nil, "level",
+		"must be one of debug, info, warn or error")
+
+	var d time.Duration
+	if input.Duration != "" {
+		d, err = time.ParseDuration(input.Duration)
+		v.Check(err == nil, "duration", "must be a duration such as 15m")
+		v.Check(d >= 0 && d <= maxLogLevelDuration, "duration",
+			"must be between 0 and "+maxLogLevelDuration.String())
+	}
+
+	if !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.SetLogLevelFor(input.Level, d)
+	if err != nil {
+		app.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	app.RequestLogger(r).Warn("Log level changed", "level", level.String(), "duration", d.String())
+
+	err = jsonz.WriteJSendSuccess(w, http.StatusOK, nil, app.logLevelStatus())
+	if err != nil {
+		app.ServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,114 @@
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+)
+
+func TestRequireAdminToken(t *testing.T) {
+	adminToken, err := config.ParseSecret("0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		adminToken    config.Secret
+		header        string
+		authorization string
+		want          int
+	}{
+		{"no admin token configured", config.Secret{}, "0123456789abcdef", "", http.StatusNotFound},
+		{"correct token", adminToken, "0123456789abcdef", "", http.StatusOK},
+		{"correct token with a user's bearer token", adminToken, "0123456789abcdef", "Bearer valid", http.StatusOK},
+		{"wrong token", adminToken, "fedcba9876543210", "", http.StatusUnauthorized},
+		{"missing token", adminToken, "", "", http.StatusUnauthorized},
+		{"token in the Authorization header", adminToken, "", "Bearer 0123456789abcdef", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(config.Server{AdminToken: tt.adminToken}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			// The admin endpoints are reachable with Authenticate() applied
+			// globally, as the admin token is not sent as a bearer token.
+			tokens := staticTokenStore{"valid": &TokenPrincipal{Subject: "user-1", Activated: true}}
+			handler := app.Authenticate(nil, tokens, app.Router)
+
+			r := httptest.NewRequest(http.MethodGet, "/admin/log-level", nil)
+			if tt.header != "" {
+				r.Header.Set(AdminTokenHeader, tt.header)
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("got status %d; want %d", w.Code, tt.want)
+			}
+			// The admin token is not a bearer token, so there is no challenge
+			// unless Authenticate() rejected a bearer token.
+			if got := w.Header().Get("WWW-Authenticate"); got != "" && tt.authorization == "" {
+				t.Errorf("got WWW-Authenticate header %q; want none", got)
+			}
+		})
+	}
+}
+
+func TestSetLogLevelKeepsHandlerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	app := New(config.Server{LogLevel: "debug"}, logger)
+	app.Logger.Info("message")
+
+	if buf.Len() > 0 {
+		t.Errorf("got %q written; want nothing below the handler's own level", buf.String())
+	}
+}
+
+func TestSetLogLevelWithCustomLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	app := New(config.Server{LogLevel: "info"}, logger)
+	logger = app.Logger.With("component", "test")
+
+	tests := []struct {
+		level     string
+		logAt     slog.Level
+		wantWrite bool
+	}{
+		{"info", slog.LevelDebug, false},
+		{"info", slog.LevelInfo, true},
+		{"debug", slog.LevelDebug, true},
+		{"error", slog.LevelWarn, false},
+		{"error", slog.LevelError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level+" "+tt.logAt.String(), func(t *testing.T) {
+			err := app.SetLogLevel(tt.level)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf.Reset()
+			logger.Log(context.Background(), tt.logAt, "message")
+
+			if got := buf.Len() > 0; got != tt.wantWrite {
+				t.Errorf("got written %t; want %t", got, tt.wantWrite)
+			}
+		})
+	}
+}
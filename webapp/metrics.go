@@ -0,0 +1,124 @@
+package webapp
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// httpMetrics holds the Prometheus metrics recorded by the Metrics middleware.
+// They are created and registered once per WebApp so that the middleware can
+// be applied more than once without the metrics being registered twice.
+type httpMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	size     *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+// metricsLabels are the labels of the per-request metrics. The route is the
+// route pattern rather than the raw path to keep the number of label values
+// bounded.
+var metricsLabels = []string{"method", "route", "status"}
+
+// newMetricsRegistry returns a new Prometheus registry containing the standard
+// Go runtime and process metrics, as well as the HTTP metrics recorded by the
+// Metrics middleware.
+func newMetricsRegistry() (*prometheus.Registry, *httpMetrics) {
+	reg := prometheus.NewRegistry()
+
+	m := &httpMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests handled.",
+		}, metricsLabels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to handle HTTP requests.",
+			Buckets: prometheus.DefBuckets,
+		}, metricsLabels),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "Size of HTTP response bodies.",
+			Buckets: prometheus.ExponentialBuckets(100, 10, 6),
+		}, metricsLabels),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests currently being handled.",
+		}),
+	}
+
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.duration,
+		m.size,
+		m.inFlight,
+	)
+
+	return reg, m
+}
+
+// MetricsHandler serves the metrics in the WebApp's MetricsRegistry in the
+// Prometheus text format, or in the OpenMetrics format if the client asks for
+// it.
+func (app *WebApp) MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(app.MetricsRegistry, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+}
+
+// Metrics is a middleware function that keeps track of a number of metrics
+// relating to HTTP requests. They are published along with any other metrics
+// in the MetricsRegistry at the /metrics endpoint.
+//
+// Each request is labelled with its method, status code and the pattern of the
+// route that handled it, such as /v1/movies/:id, so that each endpoint can be
+// monitored separately. Routes must be registered with the WebApp's Handle()
+// or HandlerFunc() methods for their patterns to be known; all other requests
+// are labelled as unmatched.
+func (app *WebApp) Metrics(next http.Handler) http.Handler {
+	m := app.httpMetrics
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		r, _ = withRouteHolder(r)
+		rec, r := RecordResponse(w, r)
+
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		next.ServeHTTP(rec.ResponseWriter(), r)
+
+		status := rec.StatusOrOK()
+
+		labels := prometheus.Labels{
+			"method": metricsMethod(r.Method),
+			"route":  routeLabel(r),
+			"status": strconv.Itoa(status),
+		}
+
+		m.requests.With(labels).Inc()
+		m.duration.With(labels).Observe(time.Since(start).Seconds())
+		m.size.With(labels).Observe(float64(rec.BytesWritten()))
+	})
+}
+
+// metricsMethod returns method if it is one of the standard HTTP methods, or
+// OTHER if not, so that clients cannot create arbitrary label values.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodConnect,
+		http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
@@ -0,0 +1,94 @@
+package webapp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// scrapeMetrics returns the metrics in app's MetricsRegistry in the Prometheus
+// text format.
+func scrapeMetrics(t *testing.T, app *WebApp) string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	app.MetricsHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body, err := io.ReadAll(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	app := newTestApp(t)
+
+	var inFlight string
+	app.HandlerFunc(http.MethodGet, "/v1/movies/:id", func(w http.ResponseWriter, r *http.Request) {
+		inFlight = scrapeMetrics(t, app)
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("movie"))
+	})
+
+	handler := app.Metrics(app.Router)
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/v1/movies/1"},
+		{http.MethodGet, "/v1/movies/2"},
+		{"PURGE", "/v1/movies/1"},
+		{"BREW", "/v1/movies/2"},
+		{"purge", "/v1/movies/3"},
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	if !strings.Contains(inFlight, "\nhttp_requests_in_flight 1\n") {
+		t.Error("got no in-flight request whilst handling one; want 1")
+	}
+
+	metrics := scrapeMetrics(t, app)
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/v1/movies/:id",status="418"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="/v1/movies/:id",status="418"} 2`,
+		`http_response_size_bytes_sum{method="GET",route="/v1/movies/:id",status="418"} 10`,
+		`http_requests_total{method="OTHER",route="unmatched",status="404"} 3`,
+		"\nhttp_requests_in_flight 0\n",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("got metrics without %s", want)
+		}
+	}
+
+	// Unknown methods all share the OTHER label value, so each of the
+	// per-request metrics has just the two series.
+	for _, name := range []string{"http_requests_total{", "http_request_duration_seconds_count{", "http_response_size_bytes_count{"} {
+		if got := strings.Count(metrics, "\n"+name); got != 2 {
+			t.Errorf("got %d %s series; want 2", got, strings.TrimSuffix(name, "{"))
+		}
+	}
+}
+
+func TestMetricsMethod(t *testing.T) {
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{http.MethodGet, "GET"},
+		{http.MethodPost, "POST"},
+		{http.MethodOptions, "OPTIONS"},
+		{"get", "OTHER"},
+		{"PURGE", "OTHER"},
+		{"", "OTHER"},
+	}
+
+	for _, tt := range tests {
+		if got := metricsMethod(tt.method); got != tt.want {
+			t.Errorf("got %q for method %q; want %q", got, tt.method, tt.want)
+		}
+	}
+}
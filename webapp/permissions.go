@@ -0,0 +1,91 @@
+package webapp
+
+import (
+	"context"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+	"github.com/m5lapp/go-service-toolkit/internal/cache"
+)
+
+// PermissionStore looks up the permissions granted to a principal, either
+// directly or through the roles they have been given. If the WebApp has one,
+// then RequirePermission checks it for any permission the Principal does not
+// have itself.
+type PermissionStore interface {
+	// PermissionsForPrincipal returns all of the permissions granted to the
+	// principal with the given ID.
+	PermissionsForPrincipal(ctx context.Context, principalID string) (access.Permissions, error)
+}
+
+// CachedPermissionStore is a PermissionStore that caches the permissions
+// returned by another PermissionStore for a fixed time to live, so that a
+// database query is not needed for every request. Changes to a principal's
+// permissions take up to the TTL to take effect unless Invalidate() is called.
+// At most cache.DefaultCapacity principals are cached, with the least recently
+// used ones evicted to make room for new ones.
+type CachedPermissionStore struct {
+	store   PermissionStore
+	entries *cache.Cache[access.Permissions]
+}
+
+// NewCachedPermissionStore returns a pointer to a new CachedPermissionStore
+// that caches the permissions from store for ttl.
+func NewCachedPermissionStore(store PermissionStore, ttl time.Duration) *CachedPermissionStore {
+	return &CachedPermissionStore{
+		store:   store,
+		entries: cache.New[access.Permissions](ttl, cache.DefaultCapacity),
+	}
+}
+
+// PermissionsForPrincipal implements the PermissionStore interface. Errors
+// from the underlying store are not cached.
+func (s *CachedPermissionStore) PermissionsForPrincipal(ctx context.Context, principalID string) (access.Permissions, error) {
+	permissions, found := s.entries.Get(principalID)
+	if found {
+		return permissions, nil
+	}
+
+	permissions, err := s.store.PermissionsForPrincipal(ctx, principalID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.entries.Set(principalID, permissions)
+
+	return permissions, nil
+}
+
+// Invalidate removes the cached permissions for the principal with the given
+// ID so that any changes to them take effect immediately.
+func (s *CachedPermissionStore) Invalidate(principalID string) {
+	s.entries.Delete(principalID)
+}
+
+// hasPermission reports whether principal has the permission with the given
+// code, either itself or in the WebApp's PermissionStore. A ScopedPrincipal
+// must have the permission itself, and if there is a PermissionStore, then the
+// principal it acts for must also have the permission in it.
+func (app *WebApp) hasPermission(ctx context.Context, principal Principal, code string) (bool, error) {
+	hasPermission := principal.HasPermission(code)
+
+	scoped, ok := principal.(ScopedPrincipal)
+	if ok && scoped.IsScoped() {
+		if !hasPermission {
+			return false, nil
+		}
+	} else if hasPermission {
+		return true, nil
+	}
+
+	if app.PermissionStore == nil {
+		return hasPermission, nil
+	}
+
+	permissions, err := app.PermissionStore.PermissionsForPrincipal(ctx, principal.ID())
+	if err != nil {
+		return false, err
+	}
+
+	return permissions.Include(code), nil
+}
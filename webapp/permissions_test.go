@@ -0,0 +1,79 @@
+package webapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+	"github.com/m5lapp/go-service-toolkit/internal/cache"
+)
+
+// countingPermissionStore is a PermissionStore that grants movies:read to
+// every principal and counts how many times it is asked, failing if err is set.
+type countingPermissionStore struct {
+	calls int
+	err   error
+}
+
+func (s *countingPermissionStore) PermissionsForPrincipal(ctx context.Context, principalID string) (access.Permissions, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return access.Permissions{"movies:read"}, nil
+}
+
+func TestCachedPermissionStore(t *testing.T) {
+	store := &countingPermissionStore{}
+	cached := NewCachedPermissionStore(store, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		permissions, err := cached.PermissionsForPrincipal(context.Background(), "user-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !permissions.Include("movies:read") {
+			t.Fatalf("got permissions %v; want movies:read to be included", permissions)
+		}
+	}
+	if store.calls != 1 {
+		t.Errorf("got %d calls to the store; want 1", store.calls)
+	}
+
+	cached.Invalidate("user-1")
+	cached.PermissionsForPrincipal(context.Background(), "user-1")
+	if store.calls != 2 {
+		t.Errorf("got %d calls to the store after Invalidate; want 2", store.calls)
+	}
+
+	// Errors are not cached.
+	store.err = errors.New("store failed")
+	for i := 0; i < 2; i++ {
+		_, err := cached.PermissionsForPrincipal(context.Background(), "user-2")
+		if !errors.Is(err, store.err) {
+			t.Fatalf("got error %v; want %v", err, store.err)
+		}
+	}
+	if store.calls != 4 {
+		t.Errorf("got %d calls to the store; want 4", store.calls)
+	}
+}
+
+func TestCachedPermissionStoreBounded(t *testing.T) {
+	store := &countingPermissionStore{}
+	cached := NewCachedPermissionStore(store, time.Minute)
+
+	for i := 0; i < cache.DefaultCapacity+100; i++ {
+		_, err := cached.PermissionsForPrincipal(context.Background(), fmt.Sprintf("user-%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := cached.entries.Len(); got != cache.DefaultCapacity {
+		t.Errorf("got %d cached principals; want %d", got, cache.DefaultCapacity)
+	}
+}
@@ -0,0 +1,232 @@
+package webapp
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+	"github.com/m5lapp/go-service-toolkit/config"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitStore keeps track of the requests made by each client so that they
+// can be rate limited. The in-memory MemoryRateLimitStore is used by default,
+// but a shared implementation such as sqldb.RateLimitStore allows all of the
+// replicas of a service to enforce a single budget per client.
+type RateLimitStore interface {
+	// Allow reports whether a request costing cost tokens from the client
+	// identified by key is permitted, given a limit of rps tokens per second
+	// on average with bursts of up to burst tokens, and takes the tokens if
+	// so. The RateLimit middleware always passes a cost of at least one.
+	Allow(ctx context.Context, key string, rps float64, burst, cost int) (access.RateLimitResult, error)
+}
+
+// MemoryRateLimitStore is a RateLimitStore that keeps a token bucket for each
+// client in memory. Its limits only apply to the process it is running in.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	clients map[string]*memoryClient
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type memoryClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewMemoryRateLimitStore returns a pointer to a new MemoryRateLimitStore. It
+// launches a goroutine to clean up clients that have not been seen for three
+// minutes every minute, which runs until Close() is called.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{
+		clients: make(map[string]*memoryClient),
+		stop:    make(chan struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+
+			s.mu.Lock()
+
+			for key, client := range s.clients {
+				if time.Since(client.lastSeen) > 3*time.Minute {
+					delete(s.clients, key)
+				}
+			}
+
+			s.mu.Unlock()
+		}
+	}()
+
+	return s
+}
+
+// Close stops the goroutine that cleans up clients. It is safe to call more
+// than once. The store can still be used afterwards, but clients that are no
+// longer seen are kept forever.
+func (s *MemoryRateLimitStore) Close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Allow implements the RateLimitStore interface.
+func (s *MemoryRateLimitStore) Allow(ctx context.Context, key string, rps float64, burst, cost int) (access.RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, found := s.clients[key]
+	if !found {
+		client = &memoryClient{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
+		s.clients[key] = client
+	}
+
+	// Apply any changes to the limits since the client was last seen.
+	if client.limiter.Limit() != rate.Limit(rps) {
+		client.limiter.SetLimit(rate.Limit(rps))
+	}
+	if client.limiter.Burst() != burst {
+		client.limiter.SetBurst(burst)
+	}
+
+	now := time.Now()
+	client.lastSeen = now
+
+	// Reserve the tokens to find out how long the client would have to wait
+	// for them, then cancel the reservation if they would have to wait at all.
+	reservation := client.limiter.ReserveN(now, cost)
+	delay := reservation.DelayFrom(now)
+	allowed := reservation.OK() && delay == 0
+	if !allowed {
+		reservation.CancelAt(now)
+	}
+
+	result := access.NewRateLimitResult(allowed, client.limiter.TokensAt(now), rps, burst, cost)
+	if !allowed && reservation.OK() {
+		result.RetryAfter = delay
+	}
+
+	return result, nil
+}
+
+// RateLimit is a middleware function that limits the number of requests a
+// client can make in a given period. The requests are tracked in the WebApp's
+// RateLimitStore, or in a new MemoryRateLimitStore if it is nil, which is
+// closed when Serve() returns.
+//
+// By default, clients are identified by their IP address and each request
+// costs one token from the client's budget of cfg.RPS tokens per second with
+// bursts of up to cfg.Burst. The WebApp's RateLimitKey and RateLimitCost
+// functions can be set to identify clients in other ways, such as by user or
+// API key, to place them into tiers, and to weight expensive requests. Each of
+// the cfg.Policies that matches a request overrides the limits for that route
+// and tier with a separate budget.
+//
+// The state of the client's budget is sent in the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers of every response, and
+// rejected requests also get a Retry-After header.
+func (app *WebApp) RateLimit(cfg config.Limiter, next http.Handler) http.Handler {
+	return app.ReloadableRateLimit(config.NewReloadable(cfg), next)
+}
+
+// ReloadableRateLimit is the same as RateLimit, except that the configuration
+// is read from cfg on each request, so the limits can be changed or the rate
+// limiter turned on or off whilst the application is running.
+func (app *WebApp) ReloadableRateLimit(cfg *config.Reloadable[config.Limiter], next http.Handler) http.Handler {
+	store := app.RateLimitStore
+	if store == nil {
+		memStore := NewMemoryRateLimitStore()
+		app.memoryRateLimitStores = append(app.memoryRateLimitStores, memStore)
+		store = memStore
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := cfg.Get()
+
+		if cfg.Active {
+			key, tier := app.rateLimitKey(r)
+
+			policy, found := cfg.Policy(r.Method, r.URL.Path, tier)
+			if found {
+				// Keep a separate budget per policy.
+				key = policy.Method + "@" + policy.Path + "|" + key
+			}
+
+			cost := 1
+			if app.RateLimitCost != nil {
+				// Every request costs at least one token, as a zero cost
+				// would be free and a negative one would refund tokens.
+				cost = max(app.RateLimitCost(r), 1)
+			}
+
+			result, err := store.Allow(r.Context(), key, policy.RPS, policy.Burst, cost)
+			if err != nil {
+				// Fail open so that a problem with the store does not take
+				// the whole service down with it.
+				app.logError(r, err)
+			} else {
+				setRateLimitHeaders(w.Header(), result)
+
+				if !result.Allowed {
+					app.RateLimitExceededResponse(w, r)
+					return
+				}
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// closeRateLimitStores closes the MemoryRateLimitStores created by the
+// RateLimit middlewares so that their cleanup goroutines stop.
+func (app *WebApp) closeRateLimitStores() {
+	for _, store := range app.memoryRateLimitStores {
+		store.Close()
+	}
+	app.memoryRateLimitStores = nil
+}
+
+// rateLimitKey returns the key and tier of the client making the request r
+// using the WebApp's RateLimitKey function. If that is nil or returns an empty
+// key, then the client's IP address from ClientIPFromRequest() is used instead.
+func (app *WebApp) rateLimitKey(r *http.Request) (string, string) {
+	if app.RateLimitKey != nil {
+		key, tier := app.RateLimitKey(r)
+		if key != "" {
+			return key, tier
+		}
+	}
+
+	return ClientIPFromRequest(r), ""
+}
+
+// setRateLimitHeaders sets the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers from the IETF RateLimit header fields draft, as well
+// as the Retry-After header if the request was not allowed. Durations are
+// rounded up to whole seconds.
+func setRateLimitHeaders(h http.Header, result access.RateLimitResult) {
+	seconds := func(d time.Duration) string {
+		return strconv.Itoa(int(math.Ceil(d.Seconds())))
+	}
+
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", seconds(result.Reset))
+
+	if !result.Allowed {
+		h.Set("Retry-After", seconds(result.RetryAfter))
+	}
+}
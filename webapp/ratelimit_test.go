@@ -0,0 +1,106 @@
+package webapp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/m5lapp/go-service-toolkit/access"
+	"github.com/m5lapp/go-service-toolkit/config"
+)
+
+// costRecordingStore is a RateLimitStore that allows every request and records
+// the cost it was asked to take.
+type costRecordingStore struct {
+	cost int
+}
+
+func (s *costRecordingStore) Allow(ctx context.Context, key string, rps float64, burst, cost int) (access.RateLimitResult, error) {
+	s.cost = cost
+	return access.NewRateLimitResult(true, float64(burst-cost), rps, burst, cost), nil
+}
+
+func TestRateLimitCost(t *testing.T) {
+	tests := []struct {
+		name     string
+		costFunc func(r *http.Request) int
+		want     int
+	}{
+		{"default", nil, 1},
+		{"weighted", func(r *http.Request) int { return 5 }, 5},
+		{"zero", func(r *http.Request) int { return 0 }, 1},
+		{"negative", func(r *http.Request) int { return -10 }, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &costRecordingStore{}
+
+			app := newTestApp(t)
+			app.RateLimitStore = store
+			app.RateLimitCost = tt.costFunc
+
+			cfg := config.Limiter{Active: true, RPS: 1, Burst: 10}
+			handler := app.RateLimit(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/movies", nil))
+
+			if store.cost != tt.want {
+				t.Errorf("got cost %d; want %d", store.cost, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreCostOverBurst(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	defer store.Close()
+
+	result, err := store.Allow(context.Background(), "client-1", 1, 10, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed {
+		t.Fatal("got a request costing more than the burst allowed")
+	}
+
+	// The denied request must not have taken any tokens.
+	result, err = store.Allow(context.Background(), "client-1", 1, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed {
+		t.Errorf("got a request costing the whole burst denied after a request costing more than it")
+	}
+}
+
+func TestRateLimitClosesDefaultStores(t *testing.T) {
+	app := newTestApp(t)
+	cfg := config.Limiter{Active: true, RPS: 1, Burst: 10}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	app.RateLimit(cfg, next)
+	app.RateLimit(cfg, next)
+
+	app.RateLimitStore = &costRecordingStore{}
+	app.RateLimit(cfg, next)
+
+	stores := app.memoryRateLimitStores
+	if len(stores) != 2 {
+		t.Fatalf("got %d default stores; want 2", len(stores))
+	}
+
+	app.closeRateLimitStores()
+	app.closeRateLimitStores()
+
+	for i, store := range stores {
+		select {
+		case <-store.stop:
+		default:
+			t.Errorf("got default store %d still running after the stores were closed", i)
+		}
+	}
+}
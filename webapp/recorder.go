@@ -0,0 +1,235 @@
+package webapp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseRecorder wraps an http.ResponseWriter to record details of the
+// response, such as its status code and size, as it is written. It is shared
+// by the Metrics and access logging middlewares, and can be used by others
+// such as compression middlewares, so that the response only needs to be
+// wrapped once.
+//
+// It passes calls through to the io.ReaderFrom interface of the wrapped writer
+// and implements Unwrap() for use with http.ResponseController. The writer
+// returned by its ResponseWriter() method also implements http.Flusher and
+// http.Hijacker when the wrapped writer does.
+type ResponseRecorder struct {
+	wrapped       http.ResponseWriter
+	start         time.Time
+	firstByte     time.Time
+	statusCode    int
+	headerWritten bool
+	bytesWritten  int64
+	body          *countingReader
+}
+
+// RecordResponse returns a ResponseRecorder wrapping w, along with a shallow
+// copy of r whose body counts the bytes read from it. If w is already a
+// ResponseRecorder, or was returned by one's ResponseWriter() method, then the
+// ResponseRecorder is returned along with r unchanged so that multiple
+// middlewares can share one.
+func RecordResponse(w http.ResponseWriter, r *http.Request) (*ResponseRecorder, *http.Request) {
+	if rw, ok := w.(interface{ recorder() *ResponseRecorder }); ok {
+		return rw.recorder(), r
+	}
+
+	rec := &ResponseRecorder{
+		wrapped: w,
+		start:   time.Now(),
+		body:    &countingReader{},
+	}
+
+	if r.Body != nil && r.Body != http.NoBody {
+		rec.body.ReadCloser = r.Body
+		r = r.WithContext(r.Context())
+		r.Body = rec.body
+	}
+
+	return rec, r
+}
+
+// ResponseWriter returns an http.ResponseWriter that writes through rec and
+// implements http.Flusher and http.Hijacker only if the wrapped writer does,
+// so that a handler checking for them with a type assertion is not misled.
+// Middlewares should pass it to the next handler rather than rec itself.
+func (rec *ResponseRecorder) ResponseWriter() http.ResponseWriter {
+	_, flusher := rec.wrapped.(http.Flusher)
+	_, hijacker := rec.wrapped.(http.Hijacker)
+
+	switch {
+	case flusher && hijacker:
+		return flushHijackRecorder{rec}
+	case flusher:
+		return flushRecorder{rec}
+	case hijacker:
+		return hijackRecorder{rec}
+	default:
+		return rec
+	}
+}
+
+// Status returns the HTTP status code of the response. If nothing has been
+// written yet, it returns 0. If only the body has been written, then it
+// returns 200 as that is what net/http sends.
+func (rec *ResponseRecorder) Status() int {
+	return rec.statusCode
+}
+
+// StatusOrOK returns the HTTP status code of the response, or 200 if nothing
+// has been written, as that is what net/http sends when a handler returns
+// without writing anything.
+func (rec *ResponseRecorder) StatusOrOK() int {
+	if rec.statusCode == 0 {
+		return http.StatusOK
+	}
+	return rec.statusCode
+}
+
+// BytesWritten returns the number of bytes of the response body written so
+// far.
+func (rec *ResponseRecorder) BytesWritten() int64 {
+	return rec.bytesWritten
+}
+
+// BytesRead returns the number of bytes of the request body read so far.
+func (rec *ResponseRecorder) BytesRead() int64 {
+	return rec.body.n
+}
+
+// TimeToFirstByte returns the time from the ResponseRecorder being created to
+// the response header being written, or zero if it has not been written yet.
+func (rec *ResponseRecorder) TimeToFirstByte() time.Duration {
+	if rec.firstByte.IsZero() {
+		return 0
+	}
+	return rec.firstByte.Sub(rec.start)
+}
+
+// Header implements the http.ResponseWriter interface.
+func (rec *ResponseRecorder) Header() http.Header {
+	return rec.wrapped.Header()
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (rec *ResponseRecorder) WriteHeader(statusCode int) {
+	rec.wrapped.WriteHeader(statusCode)
+
+	// Informational responses can be followed by another header.
+	if !rec.headerWritten && (statusCode < 100 || statusCode > 199) {
+		rec.statusCode = statusCode
+		rec.headerWritten = true
+		rec.firstByte = time.Now()
+	}
+}
+
+// Write implements the http.ResponseWriter interface.
+func (rec *ResponseRecorder) Write(b []byte) (int, error) {
+	rec.implicitHeader()
+
+	n, err := rec.wrapped.Write(b)
+	rec.bytesWritten += int64(n)
+
+	return n, err
+}
+
+// ReadFrom implements the io.ReaderFrom interface, which allows net/http to
+// use sendfile(2) when serving files.
+func (rec *ResponseRecorder) ReadFrom(src io.Reader) (int64, error) {
+	rec.implicitHeader()
+
+	var n int64
+	var err error
+
+	if rf, ok := rec.wrapped.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		// Hide the ReadFrom method of the wrapped writer from io.Copy to
+		// avoid infinite recursion.
+		n, err = io.Copy(struct{ io.Writer }{rec.wrapped}, src)
+	}
+	rec.bytesWritten += n
+
+	return n, err
+}
+
+// Unwrap returns the wrapped http.ResponseWriter for use by
+// http.ResponseController.
+func (rec *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return rec.wrapped
+}
+
+func (rec *ResponseRecorder) recorder() *ResponseRecorder {
+	return rec
+}
+
+// flush flushes the wrapped writer, which must implement http.Flusher.
+func (rec *ResponseRecorder) flush() {
+	rec.implicitHeader()
+	rec.wrapped.(http.Flusher).Flush()
+}
+
+// hijack hijacks the connection of the wrapped writer, which must implement
+// http.Hijacker.
+func (rec *ResponseRecorder) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	// Once hijacked, the connection is no longer an HTTP response, so record
+	// it as having switched protocols.
+	if !rec.headerWritten {
+		rec.statusCode = http.StatusSwitchingProtocols
+		rec.headerWritten = true
+		rec.firstByte = time.Now()
+	}
+
+	return rec.wrapped.(http.Hijacker).Hijack()
+}
+
+// flushRecorder is a ResponseRecorder whose wrapped writer implements
+// http.Flusher.
+type flushRecorder struct{ *ResponseRecorder }
+
+// Flush implements the http.Flusher interface.
+func (fr flushRecorder) Flush() { fr.flush() }
+
+// hijackRecorder is a ResponseRecorder whose wrapped writer implements
+// http.Hijacker.
+type hijackRecorder struct{ *ResponseRecorder }
+
+// Hijack implements the http.Hijacker interface.
+func (hr hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) { return hr.hijack() }
+
+// flushHijackRecorder is a ResponseRecorder whose wrapped writer implements
+// both http.Flusher and http.Hijacker.
+type flushHijackRecorder struct{ *ResponseRecorder }
+
+// Flush implements the http.Flusher interface.
+func (fhr flushHijackRecorder) Flush() { fhr.flush() }
+
+// Hijack implements the http.Hijacker interface.
+func (fhr flushHijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) { return fhr.hijack() }
+
+// implicitHeader records the status as 200 if the body is written before the
+// header, as net/http does.
+func (rec *ResponseRecorder) implicitHeader() {
+	if !rec.headerWritten {
+		rec.statusCode = http.StatusOK
+		rec.headerWritten = true
+		rec.firstByte = time.Now()
+	}
+}
+
+// countingReader counts the bytes read from the wrapped io.ReadCloser.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read implements the io.Reader interface.
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
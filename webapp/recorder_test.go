@@ -0,0 +1,92 @@
+package webapp
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// plainWriter is an http.ResponseWriter that supports neither flushing nor
+// hijacking.
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+// hijackWriter is an http.ResponseWriter that supports hijacking but not
+// flushing.
+type hijackWriter struct {
+	http.ResponseWriter
+	hijacked bool
+}
+
+func (hw *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hw.hijacked = true
+	return nil, nil, nil
+}
+
+// flushHijackWriter is an http.ResponseWriter that supports both flushing and
+// hijacking.
+type flushHijackWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (fhw flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func TestResponseRecorderInterfaces(t *testing.T) {
+	tests := []struct {
+		name         string
+		w            http.ResponseWriter
+		wantFlusher  bool
+		wantHijacker bool
+	}{
+		{"neither", plainWriter{httptest.NewRecorder()}, false, false},
+		{"flusher", httptest.NewRecorder(), true, false},
+		{"hijacker", &hijackWriter{ResponseWriter: plainWriter{httptest.NewRecorder()}}, false, true},
+		{"both", flushHijackWriter{httptest.NewRecorder()}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, r := RecordResponse(tt.w, httptest.NewRequest(http.MethodGet, "/", nil))
+			w := rec.ResponseWriter()
+
+			_, flusher := w.(http.Flusher)
+			_, hijacker := w.(http.Hijacker)
+			if flusher != tt.wantFlusher || hijacker != tt.wantHijacker {
+				t.Fatalf("got flusher %t and hijacker %t; want %t and %t",
+					flusher, hijacker, tt.wantFlusher, tt.wantHijacker)
+			}
+
+			// A second middleware shares the same ResponseRecorder.
+			again, _ := RecordResponse(w, r)
+			if again != rec {
+				t.Error("got a new ResponseRecorder for an already recorded response")
+			}
+		})
+	}
+}
+
+func TestResponseRecorderFlushAndHijack(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec, _ := RecordResponse(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	rec.ResponseWriter().(http.Flusher).Flush()
+	if !w.Flushed || rec.Status() != http.StatusOK {
+		t.Errorf("got flushed %t and status %d; want true and %d", w.Flushed, rec.Status(), http.StatusOK)
+	}
+
+	hw := &hijackWriter{ResponseWriter: plainWriter{httptest.NewRecorder()}}
+	rec, _ = RecordResponse(hw, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	_, _, err := rec.ResponseWriter().(http.Hijacker).Hijack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hw.hijacked || rec.Status() != http.StatusSwitchingProtocols {
+		t.Errorf("got hijacked %t and status %d; want true and %d", hw.hijacked, rec.Status(), http.StatusSwitchingProtocols)
+	}
+}
@@ -0,0 +1,141 @@
+package webapp
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReloadFunc loads and validates new configuration values when the WebApp's
+// configuration is reloaded. If it succeeds, it returns an apply function that
+// puts the new values into use; this must not fail. A ReloadFunc must not
+// change any running values itself.
+type ReloadFunc func() (apply func(), err error)
+
+// reloader holds the functions to be called when the configuration of a
+// WebApp is reloaded.
+type reloader struct {
+	mu  sync.Mutex
+	fns []ReloadFunc
+}
+
+// OnReload registers fn to be called whenever the WebApp's configuration is
+// reloaded, either by a call to Reload(), a SIGHUP signal being received by
+// Serve() or a change to a file being watched with WatchFiles(). Functions are
+// called in the order they were registered.
+//
+// A typical function registers new config structs onto a new flag.FlagSet,
+// runs a config.Loader with that FlagSet and validates the result, then
+// returns an apply function that pushes the new values into the
+// config.Reloadable values used by the middlewares. Loading into new structs,
+// rather than those bound to the running FlagSet, is what keeps the running
+// values untouched by a rejected reload. It also reads any config.Secret set
+// from a file or environment variable again, so rotated credentials such as
+// the SMTP password are picked up. If the function returns an error, then the
+// reload is rejected: none of the apply functions are called and the error is
+// logged.
+func (app *WebApp) OnReload(fn ReloadFunc) {
+	app.reloader.mu.Lock()
+	defer app.reloader.mu.Unlock()
+
+	app.reloader.fns = append(app.reloader.fns, fn)
+}
+
+// Reload calls each of the functions registered with OnReload() in turn,
+// stopping at the first one to return an error. The apply functions that they
+// return are only called once all of them have succeeded, so a reload is
+// applied either in full or not at all. Only one reload can be in progress at
+// a time.
+func (app *WebApp) Reload() error {
+	app.reloader.mu.Lock()
+	defer app.reloader.mu.Unlock()
+
+	applies := make([]func(), 0, len(app.reloader.fns))
+	for _, fn := range app.reloader.fns {
+		apply, err := fn()
+		if err != nil {
+			err = fmt.Errorf("configuration reload rejected: %w", err)
+			app.Logger.Error(err.Error())
+			return err
+		}
+		if apply != nil {
+			applies = append(applies, apply)
+		}
+	}
+
+	for _, apply := range applies {
+		apply()
+	}
+
+	app.Logger.Info("Configuration reloaded")
+	return nil
+}
+
+// WatchFiles checks each of the files in paths every interval and reloads the
+// WebApp's configuration if any of them have changed. It returns a function
+// that stops the files from being watched.
+func (app *WebApp) WatchFiles(interval time.Duration, paths ...string) (stop func()) {
+	return watchFiles(interval, paths, func() {
+		app.Reload()
+	})
+}
+
+// fileState is used to detect when a file has changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchFiles starts a goroutine that polls the files in paths every interval
+// and calls onChange if the modification time or size of any of them has
+// changed since the last check. Polling is used rather than filesystem events
+// as the files in Kubernetes ConfigMap and Secret volumes are replaced by
+// swapping symlinks, which is not reliably reported by inotify. It returns a
+// function that stops the goroutine.
+func watchFiles(interval time.Duration, paths []string, onChange func()) (stop func()) {
+	stat := func() map[string]fileState {
+		states := make(map[string]fileState, len(paths))
+		for _, path := range paths {
+			fi, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			states[path] = fileState{modTime: fi.ModTime(), size: fi.Size()}
+		}
+		return states
+	}
+
+	done := make(chan struct{})
+	last := stat()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				current := stat()
+				changed := len(current) != len(last)
+				for path, state := range current {
+					if last[path] != state {
+						changed = true
+					}
+				}
+
+				last = current
+				if changed {
+					onChange()
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
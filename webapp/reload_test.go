@@ -0,0 +1,47 @@
+package webapp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	errInvalid := errors.New("invalid configuration")
+
+	tests := []struct {
+		name        string
+		errs        []error
+		wantErr     bool
+		wantApplied int
+	}{
+		{"no functions", nil, false, 0},
+		{"all succeed", []error{nil, nil, nil}, false, 3},
+		{"first fails", []error{errInvalid, nil, nil}, true, 0},
+		{"last fails", []error{nil, nil, errInvalid}, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+
+			var applied int
+			for _, err := range tt.errs {
+				err := err
+				app.OnReload(func() (func(), error) {
+					if err != nil {
+						return nil, err
+					}
+					return func() { applied++ }, nil
+				})
+			}
+
+			err := app.Reload()
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("got error %v; want error %t", err, tt.wantErr)
+			}
+			if applied != tt.wantApplied {
+				t.Errorf("got %d functions applied; want %d", applied, tt.wantApplied)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package webapp
+
+import (
+	"net/http"
+
+	"github.com/m5lapp/go-service-toolkit/requestid"
+)
+
+// RequestID is a middleware function that gives each request an ID so that
+// everything resulting from it can be correlated. The ID in the request's
+// X-Request-ID header is used if there is a valid one, otherwise a new one is
+// generated. The ID is stored in the request context, where logError, the
+// AccessLog middleware and jsonz.RequestJSendContext() pick it up, and it is
+// sent back to the client in the X-Request-ID response header.
+//
+// It should be applied as one of the outermost middlewares so that the ID is
+// available to all of the others.
+func (app *WebApp) RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+
+		ctx := requestid.NewContext(r.Context(), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromRequest returns the ID stored in the context of r by the
+// RequestID middleware, or an empty string if there is not one.
+func RequestIDFromRequest(r *http.Request) string {
+	return requestid.FromContext(r.Context())
+}
@@ -0,0 +1,74 @@
+package webapp
+
+import (
+	"context"
+	"net/http"
+)
+
+// routeUnmatched is the route reported for requests that did not match any
+// route registered with Handle() or HandlerFunc().
+const routeUnmatched = "unmatched"
+
+const routeContextKey = contextKey("route")
+
+// routeHolder records the route pattern matched by the router. A pointer to
+// one is stored in the request context by the outermost middleware that needs
+// it so that the pattern set inside the router can be read on the way back out.
+type routeHolder struct {
+	pattern string
+}
+
+// withRouteHolder returns r with a routeHolder in its context, along with the
+// holder. If r already has one, then r is returned unchanged.
+func withRouteHolder(r *http.Request) (*http.Request, *routeHolder) {
+	holder, ok := r.Context().Value(routeContextKey).(*routeHolder)
+	if ok {
+		return r, holder
+	}
+
+	holder = &routeHolder{}
+	ctx := context.WithValue(r.Context(), routeContextKey, holder)
+
+	return r.WithContext(ctx), holder
+}
+
+// RouteFromRequest returns the pattern of the route that matched r, such as
+// /v1/movies/:id, as opposed to the raw path. It returns an empty string if no
+// route registered with Handle() or HandlerFunc() has matched r yet.
+func RouteFromRequest(r *http.Request) string {
+	holder, ok := r.Context().Value(routeContextKey).(*routeHolder)
+	if !ok {
+		return ""
+	}
+	return holder.pattern
+}
+
+// routeLabel returns the pattern of the route that matched r for use in logs,
+// metrics and traces, or routeUnmatched if no route has matched it.
+func routeLabel(r *http.Request) string {
+	pattern := RouteFromRequest(r)
+	if pattern == "" {
+		return routeUnmatched
+	}
+	return pattern
+}
+
+// Handle registers handler with the Router for requests matching method and
+// path, in the same way as httprouter.Router.Handler(). Unlike registering it
+// with the Router directly, the path pattern is recorded in the request context
+// so that middlewares such as Metrics can report on each route separately
+// without having to use the raw paths, which may contain IDs.
+func (app *WebApp) Handle(method, path string, handler http.Handler) {
+	app.Router.Handler(method, path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, holder := withRouteHolder(r)
+		holder.pattern = path
+
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+// HandlerFunc is the same as Handle, except that it takes an
+// http.HandlerFunc.
+func (app *WebApp) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	app.Handle(method, path, handler)
+}
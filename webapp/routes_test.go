@@ -0,0 +1,86 @@
+package webapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteLabel(t *testing.T) {
+	tests := []struct {
+		name                   string
+		method                 string
+		path                   string
+		handleMethodNotAllowed bool
+		wantStatus             int
+		wantRoute              string
+	}{
+		{"matched route", http.MethodGet, "/v1/movies/42", false, http.StatusOK, "/v1/movies/:id"},
+		{"matched HandlerFunc route", http.MethodPost, "/v1/movies", false, http.StatusCreated, "/v1/movies"},
+		{"catch-all route", http.MethodGet, "/static/css/site.css", false, http.StatusOK, "/static/*filepath"},
+		{"not found", http.MethodGet, "/v1/actors/42", false, http.StatusNotFound, routeUnmatched},
+		{"method not allowed", http.MethodDelete, "/v1/movies/42", true, http.StatusMethodNotAllowed, routeUnmatched},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.Router.HandleMethodNotAllowed = tt.handleMethodNotAllowed
+
+			ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			app.Handle(http.MethodGet, "/v1/movies/:id", ok)
+			app.Handle(http.MethodGet, "/static/*filepath", ok)
+			app.HandlerFunc(http.MethodPost, "/v1/movies", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+			})
+
+			var route, label string
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r, _ = withRouteHolder(r)
+				app.Router.ServeHTTP(w, r)
+				route, label = RouteFromRequest(r), routeLabel(r)
+			})
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %d; want %d", rr.Code, tt.wantStatus)
+			}
+			if label != tt.wantRoute {
+				t.Errorf("got route label %q; want %q", label, tt.wantRoute)
+			}
+			if tt.wantRoute != routeUnmatched && route != tt.wantRoute {
+				t.Errorf("got route %q from RouteFromRequest; want %q", route, tt.wantRoute)
+			}
+			if tt.wantRoute == routeUnmatched && route != "" {
+				t.Errorf("got route %q from RouteFromRequest; want none", route)
+			}
+		})
+	}
+}
+
+func TestRouteFromRequestWithoutMiddleware(t *testing.T) {
+	app := newTestApp(t)
+
+	// Without an outer middleware, the route is still available to the
+	// handler itself.
+	var route string
+	app.HandlerFunc(http.MethodGet, "/v1/movies/:id", func(w http.ResponseWriter, r *http.Request) {
+		route = RouteFromRequest(r)
+	})
+
+	app.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/movies/42", nil))
+
+	if route != "/v1/movies/:id" {
+		t.Errorf("got route %q; want %q", route, "/v1/movies/:id")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies/42", nil)
+	if got := RouteFromRequest(r); got != "" {
+		t.Errorf("got route %q for a request that has not been routed; want none", got)
+	}
+	if got := routeLabel(r); got != routeUnmatched {
+		t.Errorf("got route label %q for a request that has not been routed; want %q", got, routeUnmatched)
+	}
+}
@@ -0,0 +1,105 @@
+package webapp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+)
+
+// certReloadInterval is how often the TLS certificate and key files are checked
+// for changes.
+const certReloadInterval = 30 * time.Second
+
+// certReloader holds a TLS certificate that is reloaded from its files
+// whenever they change on disk, so that renewed certificates are picked up
+// without restarting the server.
+type certReloader struct {
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	certFile string
+	keyFile  string
+}
+
+// newCertReloader loads the certificate from certFile and keyFile and returns
+// a certReloader holding it.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{certFile: certFile, keyFile: keyFile}
+
+	err := cr.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+// reload loads the certificate from its files again. The current certificate
+// is kept if it cannot be loaded.
+func (cr *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.cert = &cert
+	return nil
+}
+
+// GetCertificate can be used as the tls.Config.GetCertificate function.
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	return cr.cert, nil
+}
+
+// tlsConfig builds a tls.Config from the TLS settings in cfg. The certificate
+// is reloaded whenever its files change until the returned stop function is
+// called.
+func (app *WebApp) tlsConfig(cfg config.Server) (*tls.Config, func(), error) {
+	cr, err := newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stop := watchFiles(certReloadInterval, []string{cfg.TLSCertFile, cfg.TLSKeyFile}, func() {
+		err := cr.reload()
+		if err != nil {
+			app.Logger.Error("Unable to reload TLS certificate", "error", err.Error())
+			return
+		}
+		app.Logger.Info("Reloaded TLS certificate", "cert_file", cfg.TLSCertFile)
+	})
+
+	tlsCfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			stop()
+			return nil, nil, errors.New("no valid CA certificates found in " + cfg.TLSClientCAFile)
+		}
+
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsCfg, stop, nil
+}
@@ -0,0 +1,199 @@
+package webapp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+)
+
+// writeCert generates a self-signed certificate for commonName and writes it
+// and its key to PEM files in dir, returning their paths and the certificate.
+func writeCert(t *testing.T, dir, commonName string) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+
+	return certFile, keyFile, cert
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// servedCommonName returns the common name of the certificate that cr serves.
+func servedCommonName(t *testing.T, cr *certReloader) string {
+	t.Helper()
+
+	cert, err := cr.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCert(t, dir, "first")
+
+	cr, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := servedCommonName(t, cr); got != "first" {
+		t.Fatalf("got certificate for %q; want %q", got, "first")
+	}
+
+	// A renewed certificate is served once it has been reloaded.
+	writeCert(t, dir, "second")
+	err = cr.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := servedCommonName(t, cr); got != "second" {
+		t.Fatalf("got certificate for %q after reloading; want %q", got, "second")
+	}
+
+	// A key that does not match the certificate leaves the old one in place.
+	otherDir := t.TempDir()
+	_, otherKeyFile, _ := writeCert(t, otherDir, "other")
+	key, err := os.ReadFile(otherKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeCert(t, dir, "third")
+	err = os.WriteFile(keyFile, key, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cr.reload()
+	if err == nil {
+		t.Fatal("got no error reloading a mismatched key pair; want one")
+	}
+	if got := servedCommonName(t, cr); got != "second" {
+		t.Errorf("got certificate for %q after a failed reload; want %q", got, "second")
+	}
+}
+
+func TestNewCertReloaderMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := newCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err == nil {
+		t.Error("got no error for missing certificate files; want one")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	app := newTestApp(t)
+	certFile, keyFile, _ := writeCert(t, t.TempDir(), "server")
+
+	tlsCfg, stop, err := app.tlsConfig(config.Server{TLSCertFile: certFile, TLSKeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+
+	if tlsCfg.MinVersion != tls.VersionTLS12 || tlsCfg.GetCertificate == nil {
+		t.Errorf("got MinVersion %#x and GetCertificate set %t; want %#x and true",
+			tlsCfg.MinVersion, tlsCfg.GetCertificate != nil, tls.VersionTLS12)
+	}
+	if tlsCfg.ClientAuth != tls.NoClientCert || tlsCfg.ClientCAs != nil {
+		t.Errorf("got ClientAuth %v and a client CA pool without a client CA file; want neither", tlsCfg.ClientAuth)
+	}
+}
+
+func TestTLSConfigClientCA(t *testing.T) {
+	app := newTestApp(t)
+	certFile, keyFile, _ := writeCert(t, t.TempDir(), "server")
+	caFile, _, ca := writeCert(t, t.TempDir(), "client-ca")
+
+	tlsCfg, stop, err := app.tlsConfig(config.Server{
+		TLSCertFile:     certFile,
+		TLSKeyFile:      keyFile,
+		TLSClientCAFile: caFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+
+	if tlsCfg.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("got ClientAuth %v; want %v", tlsCfg.ClientAuth, tls.RequireAndVerifyClientCert)
+	}
+
+	want := x509.NewCertPool()
+	want.AddCert(ca)
+	if tlsCfg.ClientCAs == nil || !tlsCfg.ClientCAs.Equal(want) {
+		t.Error("got a client CA pool that does not hold just the client CA certificate")
+	}
+
+	// A client CA file without any certificates in it is rejected.
+	badCAFile := filepath.Join(t.TempDir(), "ca.pem")
+	err = os.WriteFile(badCAFile, []byte("not a certificate"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = app.tlsConfig(config.Server{
+		TLSCertFile:     certFile,
+		TLSKeyFile:      keyFile,
+		TLSClientCAFile: badCAFile,
+	})
+	if err == nil {
+		t.Error("got no error for a client CA file without certificates; want one")
+	}
+}
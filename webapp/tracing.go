@@ -0,0 +1,65 @@
+package webapp
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the OpenTelemetry tracer used by this package.
+const tracerName = "github.com/m5lapp/go-service-toolkit/webapp"
+
+// Trace is a middleware function that creates an OpenTelemetry server span for
+// each request, using the global tracer provider and propagator installed by
+// tracing.Setup(). If the request carries a W3C traceparent header, then the
+// span is created as a child of the caller's span so that the trace continues
+// across services.
+//
+// Spans are named after the method and the route pattern registered with
+// Handle() or HandlerFunc(), such as GET /v1/movies/:id, rather than the raw
+// path. Responses with a 5xx status code mark the span as an error.
+func (app *WebApp) Trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := otel.Tracer(tracerName).Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("client.address", ClientIPFromRequest(r)),
+				attribute.String("user_agent.original", r.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		if id := RequestIDFromRequest(r); id != "" {
+			span.SetAttributes(attribute.String("http.request.id", id))
+		}
+
+		r, _ = withRouteHolder(r.WithContext(ctx))
+		rec, r := RecordResponse(w, r)
+
+		next.ServeHTTP(rec.ResponseWriter(), r)
+
+		status := rec.StatusOrOK()
+
+		if pattern := RouteFromRequest(r); pattern != "" {
+			span.SetAttributes(attribute.String("http.route", pattern))
+		}
+
+		span.SetName(r.Method + " " + routeLabel(r))
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", status),
+			attribute.Int64("http.response.body.size", rec.BytesWritten()),
+		)
+
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
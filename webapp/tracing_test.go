@@ -0,0 +1,132 @@
+package webapp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+	"github.com/m5lapp/go-service-toolkit/serialisation/jsonz"
+	"github.com/m5lapp/go-service-toolkit/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestExporter installs a global tracer provider that records every span in
+// the returned exporter, along with the W3C trace context propagator. Both are
+// restored when the test finishes.
+func newTestExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracing.NewTracerProvider(config.Tracing{ServiceName: "test", SampleRatio: 1}, sdktrace.WithSyncer(exporter))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	return exporter
+}
+
+func TestTrace(t *testing.T) {
+	exporter := newTestExporter(t)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonz.WriteJSendSuccess(w, http.StatusOK, nil, nil)
+	}))
+	defer upstream.Close()
+
+	app := newTestApp(t)
+	app.HandlerFunc(http.MethodGet, "/v1/movies/:id", func(w http.ResponseWriter, r *http.Request) {
+		_, _, err := jsonz.RequestJSendContext(r.Context(), http.MethodGet, upstream.URL+"/v1/ratings", time.Second, nil)
+		if err != nil {
+			app.ServerErrorResponse(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	app.HandlerFunc(http.MethodGet, "/v1/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	handler := app.Trace(app.Router)
+
+	caller := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x0a, 0xf7},
+		SpanID:     trace.SpanID{0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		parent     trace.SpanContext
+		wantName   string
+		wantStatus codes.Code
+		wantSpans  int
+	}{
+		{"matched route", "/v1/movies/1", trace.SpanContext{}, "GET /v1/movies/:id", codes.Unset, 2},
+		{"continues the caller's trace", "/v1/movies/1", caller, "GET /v1/movies/:id", codes.Unset, 2},
+		{"server error", "/v1/broken", trace.SpanContext{}, "GET /v1/broken", codes.Error, 1},
+		{"unmatched route", "/v1/missing", trace.SpanContext{}, "GET " + routeUnmatched, codes.Unset, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.parent.IsValid() {
+				otel.GetTextMapPropagator().Inject(trace.ContextWithSpanContext(context.Background(), tt.parent),
+					propagation.HeaderCarrier(r.Header))
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			spans := exporter.GetSpans()
+			if len(spans) != tt.wantSpans {
+				t.Fatalf("got %d spans; want %d", len(spans), tt.wantSpans)
+			}
+
+			// Spans are exported as they end, so the server span is last.
+			server := spans[len(spans)-1]
+			if server.Name != tt.wantName {
+				t.Errorf("got span name %q; want %q", server.Name, tt.wantName)
+			}
+			if server.SpanKind != trace.SpanKindServer {
+				t.Errorf("got span kind %s; want %s", server.SpanKind, trace.SpanKindServer)
+			}
+			if server.Status.Code != tt.wantStatus {
+				t.Errorf("got span status %s; want %s", server.Status.Code, tt.wantStatus)
+			}
+			if tt.parent.IsValid() {
+				if server.SpanContext.TraceID() != tt.parent.TraceID() || server.Parent.SpanID() != tt.parent.SpanID() {
+					t.Errorf("got span in trace %s with parent %s; want trace %s and parent %s",
+						server.SpanContext.TraceID(), server.Parent.SpanID(), tt.parent.TraceID(), tt.parent.SpanID())
+				}
+			}
+
+			if tt.wantSpans > 1 {
+				client := spans[0]
+				if client.SpanKind != trace.SpanKindClient {
+					t.Errorf("got span kind %s; want %s", client.SpanKind, trace.SpanKindClient)
+				}
+				if client.Parent.SpanID() != server.SpanContext.SpanID() ||
+					client.SpanContext.TraceID() != server.SpanContext.TraceID() {
+					t.Errorf("got client span with parent %s; want the server span %s",
+						client.Parent.SpanID(), server.SpanContext.SpanID())
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"expvar"
+	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,7 +15,7 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/m5lapp/go-service-toolkit/config"
-	"golang.org/x/exp/slog"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // WebApp represents a generic, base web application or API that provides core
@@ -22,19 +24,72 @@ import (
 type WebApp struct {
 	ServerConfig config.Server
 	Logger       *slog.Logger
+	LogLevel     *slog.LevelVar
 	Router       *httprouter.Router
 	Started      time.Time
 	Wg           *sync.WaitGroup
+
+	// MetricsRegistry holds the metrics published at the /metrics endpoint.
+	// Services can register their own metrics with it.
+	MetricsRegistry *prometheus.Registry
+
+	// PermissionStore is checked by the RequirePermission middleware for any
+	// permissions that a Principal does not have itself. It is usually a
+	// CachedPermissionStore wrapping a sqldb.PermissionStore.
+	PermissionStore PermissionStore
+
+	// RateLimitStore is used by the RateLimit middleware to keep track of
+	// requests. If it is nil, then each RateLimit middleware keeps track of
+	// them in memory.
+	RateLimitStore RateLimitStore
+	// RateLimitKey returns the key that identifies the client making a request
+	// and the tier the client belongs to, such as free or paid, for the
+	// RateLimit middleware. If it is nil, the client's IP address is used.
+	RateLimitKey func(r *http.Request) (key, tier string)
+	// RateLimitCost returns the number of tokens a request costs for the
+	// RateLimit middleware. If it is nil, every request costs one token, and
+	// a cost of less than one is treated as one.
+	RateLimitCost func(r *http.Request) int
+
+	httpMetrics      *httpMetrics
+	logLevelReverter *logLevelReverter
+	reloader         *reloader
+
+	// memoryRateLimitStores are the stores created by the RateLimit
+	// middlewares when there is no RateLimitStore, which are closed when
+	// Serve() returns.
+	memoryRateLimitStores []*MemoryRateLimitStore
 }
 
-// New returns a new WebApp with the given ServerConfig and Logger set.
+// New returns a new WebApp with the given ServerConfig and Logger set. The
+// LogLevel is initialised from the ServerConfig. If logger is nil, then one
+// that writes to os.Stdout at the LogLevel is created with NewLogger().
+// Otherwise, logger's handler is wrapped so that records below the LogLevel do
+// not reach it, which means that changing the LogLevel, such as through the
+// /admin/log-level endpoint, takes effect for it too. The handler's own level
+// still applies, so it should be created with the lowest level that might be
+// wanted.
 func New(cfg config.Server, logger *slog.Logger) WebApp {
+	newLogger, level := NewLogger(os.Stdout, cfg)
+	if logger == nil {
+		logger = newLogger
+	} else {
+		logger = slog.New(&levelHandler{level: level, handler: logger.Handler()})
+	}
+
+	registry, httpMetrics := newMetricsRegistry()
+
 	wa := WebApp{
-		ServerConfig: cfg,
-		Logger:       logger,
-		Router:       &httprouter.Router{},
-		Started:      time.Now(),
-		Wg:           &sync.WaitGroup{},
+		ServerConfig:     cfg,
+		Logger:           logger,
+		LogLevel:         level,
+		Router:           &httprouter.Router{},
+		Started:          time.Now(),
+		Wg:               &sync.WaitGroup{},
+		MetricsRegistry:  registry,
+		httpMetrics:      httpMetrics,
+		logLevelReverter: &logLevelReverter{},
+		reloader:         &reloader{},
 	}
 
 	// Now that the WebApp is created, we can add the basic, common routes.
@@ -48,25 +103,82 @@ func (app *WebApp) baseRoutes() {
 	app.Router.MethodNotAllowed = http.HandlerFunc(app.MethodNotAllowedError)
 	app.Router.NotFound = http.HandlerFunc(app.NotFoundResponse)
 
-	app.Router.Handler(http.MethodGet, "/debug", expvar.Handler())
-	app.Router.HandlerFunc(http.MethodGet, "/health", app.HealthCheckHandler)
+	app.Handle(http.MethodGet, "/debug", expvar.Handler())
+	app.HandlerFunc(http.MethodGet, "/health", app.HealthCheckHandler)
+	app.Handle(http.MethodGet, "/metrics", app.MetricsHandler())
+	app.HandlerFunc(http.MethodGet, "/admin/log-level", app.RequireAdminToken(app.showLogLevelHandler))
+	app.HandlerFunc(http.MethodPut, "/admin/log-level", app.RequireAdminToken(app.updateLogLevelHandler))
+}
+
+// StdLogger returns a *log.Logger that writes each line logged to it as a
+// record at the given level to the WebApp's Logger. It allows the Logger to be
+// used with the http.Server and third-party libraries that expect a
+// *log.Logger.
+func (app *WebApp) StdLogger(level slog.Level) *log.Logger {
+	return slog.NewLogLogger(app.Logger.Handler(), level)
 }
 
 // Serve configures an http.Server and starts it running whilst also spawning a
 // goroutine to catch certain interrupt signals and handle them more gracefully.
+// A SIGHUP signal causes the configuration to be reloaded; see OnReload(). The
+// timeouts and limits are taken from the ServerConfig, with any zero timeouts
+// replaced by their defaults. The server's internal errors, such as TLS
+// handshake failures, are logged at the ServerConfig's ErrorLogLevel. If TLS is
+// configured, then HTTPS is served and the certificate is reloaded
+// automatically whenever its files change.
 func (app *WebApp) Serve(routes http.Handler) error {
+	defer app.closeRateLimitStores()
+
+	cfg := app.ServerConfig
+
+	// An empty or invalid level leaves the server's errors at the error level.
+	errorLevel := slog.LevelError
+	errorLevel.UnmarshalText([]byte(cfg.ErrorLogLevel))
+
 	srv := &http.Server{
-		Addr: app.ServerConfig.Addr,
-		// TODO: Get this working with slog.
-		//ErrorLog:     log.New(app.Logger, "", 0),
-		Handler:      routes,
-		IdleTimeout:  60 * time.Second,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		Addr:              cfg.Addr,
+		ErrorLog:          app.StdLogger(errorLevel),
+		Handler:           routes,
+		IdleTimeout:       durationOrDefault(cfg.IdleTimeout, config.DefaultIdleTimeout),
+		ReadTimeout:       durationOrDefault(cfg.ReadTimeout, config.DefaultReadTimeout),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      durationOrDefault(cfg.WriteTimeout, config.DefaultWriteTimeout),
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+
+	if cfg.TLSEnabled() {
+		tlsCfg, stopWatching, err := app.tlsConfig(cfg)
+		if err != nil {
+			return err
+		}
+		defer stopWatching()
+
+		srv.TLSConfig = tlsCfg
 	}
 
 	shutdownError := make(chan error)
 
+	// Start a background goroutine to reload the configuration whenever a
+	// SIGHUP signal is received. Reloading does not affect open connections.
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	stopReloading := make(chan struct{})
+	defer close(stopReloading)
+
+	go func() {
+		for {
+			select {
+			case <-stopReloading:
+				return
+			case s := <-hangup:
+				app.Logger.Info("Reloading configuration", "signal", s.String())
+				app.Reload()
+			}
+		}
+	}()
+
 	// Start a background goroutine to catch shutdown signals.
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -78,7 +190,8 @@ func (app *WebApp) Serve(routes http.Handler) error {
 
 		app.Logger.Info("Shutting down server", "signal", s.String())
 
-		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		timeout := durationOrDefault(cfg.ShutdownTimeout, config.DefaultShutdownTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 
 		err := srv.Shutdown(ctx)
@@ -92,9 +205,16 @@ func (app *WebApp) Serve(routes http.Handler) error {
 		shutdownError <- nil
 	}()
 
-	app.Logger.Info("Starting server", "env", app.ServerConfig.Env, "addr", srv.Addr)
+	app.Logger.Info("Starting server", "env", cfg.Env, "addr", srv.Addr, "tls", cfg.TLSEnabled())
 
-	err := srv.ListenAndServe()
+	var err error
+	if cfg.TLSEnabled() {
+		// The certificate is provided by the TLSConfig so that it can be
+		// reloaded, hence the empty file names.
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
@@ -108,3 +228,11 @@ func (app *WebApp) Serve(routes http.Handler) error {
 	app.Logger.Info("Stopped server", "addr", srv.Addr)
 	return nil
 }
+
+// durationOrDefault returns d, or def if d is zero.
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,20 @@
+package webapp
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/m5lapp/go-service-toolkit/config"
+)
+
+// newTestApp returns a WebApp that discards its logs.
+func newTestApp(t *testing.T) *WebApp {
+	t.Helper()
+
+	app := New(config.Server{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(app.Wg.Wait)
+	t.Cleanup(app.closeRateLimitStores)
+
+	return &app
+}